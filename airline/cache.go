@@ -0,0 +1,278 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheRule caches the responses of the URLs containing Match for TTL,
+// regardless of what the server says. A zero TTL disables the caching of those URLs.
+type CacheRule struct {
+	Match string
+	TTL   time.Duration
+}
+
+// CachePolicy is the caching of the responses of one source.
+type CachePolicy struct {
+	// Name of the source, the cache is in the Name subdirectory of CacheDir.
+	Name string
+	// Rules are checked in order, the first matching wins.
+	// The responses of the URLs not matching any rule are cached as the server allows.
+	Rules []CacheRule
+}
+
+// WithCachePolicy sets the policy of the cache (if the cache is enabled).
+func WithCachePolicy(policy CachePolicy) ClientOption {
+	return func(cfg *clientConfig) { cfg.policy = &policy }
+}
+
+// WithoutCache disables the cache, even if it is enabled by the other options or NewClient.
+func WithoutCache() ClientOption {
+	return func(cfg *clientConfig) { cfg.noCache = true }
+}
+
+// CacheDir returns the root of the HTTP caches ($XDG_CACHE_HOME/airline).
+func CacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = "/tmp"
+	}
+	return filepath.Join(dir, "airline")
+}
+
+// TTL returns the TTL of the first matching rule.
+func (p CachePolicy) TTL(URL string) (time.Duration, bool) {
+	for _, r := range p.Rules {
+		if strings.Contains(URL, r.Match) {
+			return r.TTL, true
+		}
+	}
+	return 0, false
+}
+
+func (p CachePolicy) transport(base http.RoundTripper) http.RoundTripper {
+	if len(p.Rules) == 0 {
+		return base
+	}
+	return policyTransport{base: base, policy: p}
+}
+
+// policyTransport rewrites the caching headers of the responses, as the policy says.
+type policyTransport struct {
+	base   http.RoundTripper
+	policy CachePolicy
+}
+
+func (pt policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := pt.base.RoundTrip(req)
+	if err != nil || resp.StatusCode >= 400 {
+		return resp, err
+	}
+	ttl, ok := pt.policy.TTL(req.URL.String())
+	if !ok {
+		return resp, nil
+	}
+	for _, k := range []string{"Expires", "Pragma", "Vary"} {
+		resp.Header.Del(k)
+	}
+	if ttl <= 0 {
+		resp.Header.Set("Cache-Control", "no-store")
+		return resp, nil
+	}
+	resp.Header.Set("Cache-Control", "max-age="+strconv.Itoa(int(ttl/time.Second)))
+	if resp.Header.Get("Date") == "" {
+		resp.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	return resp, nil
+}
+
+// CacheStat is the statistics of the cache of one source.
+type CacheStat struct {
+	Name           string
+	Entries, Stale int
+	Size           int64
+}
+
+// FlatCache is the name of the files of the old, flat cache directly under the root.
+const FlatCache = "(flat)"
+
+// CacheStats returns the statistics of the caches under root (CacheDir),
+// or only of the named one.
+func CacheStats(root, name string, now time.Time) ([]CacheStat, error) {
+	var stats []CacheStat
+	err := walkCache(root, name, func(name, path string, info fs.FileInfo) error {
+		if len(stats) == 0 || stats[len(stats)-1].Name != name {
+			stats = append(stats, CacheStat{Name: name})
+		}
+		st := &stats[len(stats)-1]
+		st.Entries++
+		st.Size += info.Size()
+		if cacheStale(path, now) {
+			st.Stale++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// ClearCache removes all the cached responses under root (CacheDir), or only the named cache.
+func ClearCache(root, name string) error {
+	names, err := cacheNames(root, name)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, nm := range names {
+		if nm != FlatCache {
+			errs = append(errs, os.RemoveAll(filepath.Join(root, nm)))
+		}
+	}
+	if slices.Contains(names, FlatCache) {
+		errs = append(errs, walkCache(root, FlatCache, func(_, path string, _ fs.FileInfo) error {
+			return os.Remove(path)
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// PruneCache removes the stale responses under root (CacheDir), or of the named cache,
+// and returns the number of the removed ones.
+func PruneCache(root, name string, now time.Time) (int, error) {
+	var n int
+	err := walkCache(root, name, func(_, path string, _ fs.FileInfo) error {
+		if !cacheStale(path, now) {
+			return nil
+		}
+		n++
+		return os.Remove(path)
+	})
+	return n, err
+}
+
+// CheckCacheName returns an error if name is not a plain name of a cache
+// (a subdirectory of the root, or FlatCache).
+func CheckCacheName(name string) error {
+	if name == "" || name == FlatCache {
+		return nil
+	}
+	if name == "." || strings.Contains(name, "..") || strings.ContainsAny(name, `/\`) || filepath.Base(name) != name {
+		return fmt.Errorf("invalid cache name %q", name)
+	}
+	return nil
+}
+
+// cacheNames returns name, or all the caches under root (with FlatCache if the root has files) if it is empty.
+func cacheNames(root, name string) ([]string, error) {
+	if name != "" {
+		return []string{name}, CheckCacheName(name)
+	}
+	dis, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	names := make([]string, 0, len(dis))
+	var flat bool
+	for _, di := range dis {
+		if di.IsDir() {
+			names = append(names, di.Name())
+		} else {
+			flat = true
+		}
+	}
+	slices.Sort(names)
+	if flat {
+		names = append([]string{FlatCache}, names...)
+	}
+	return names, err
+}
+
+func walkCache(root, name string, f func(name, path string, info fs.FileInfo) error) error {
+	names, err := cacheNames(root, name)
+	if err != nil {
+		return err
+	}
+	for _, nm := range names {
+		if nm == FlatCache {
+			// only the files of the root, the subdirectories are the other caches
+			dis, err := os.ReadDir(root)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			for _, di := range dis {
+				if di.IsDir() {
+					continue
+				}
+				info, err := di.Info()
+				if err != nil {
+					return err
+				}
+				if err = f(nm, filepath.Join(root, di.Name()), info); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		err := filepath.WalkDir(filepath.Join(root, nm), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return f(nm, path, info)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheStale reports whether the cached response in the file is not fresh anymore at now
+// (or cannot be read).
+func cacheStale(path string, now time.Time) bool {
+	fh, err := os.Open(path)
+	if err != nil {
+		return true
+	}
+	defer fh.Close()
+	resp, err := http.ReadResponse(bufio.NewReader(fh), nil)
+	if err != nil {
+		return true
+	}
+	resp.Body.Close()
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return true
+	}
+	for _, s := range strings.Split(resp.Header.Get("Cache-Control"), ",") {
+		if k, v, _ := strings.Cut(strings.TrimSpace(s), "="); k == "max-age" {
+			secs, _ := strconv.Atoi(v)
+			return !date.Add(time.Duration(secs) * time.Second).After(now)
+		}
+	}
+	if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+		return !expires.After(now)
+	}
+	return true
+}
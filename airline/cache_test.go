@@ -0,0 +1,93 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachePolicy(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", root)
+	root = filepath.Join(root, "airline")
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "no-cache, no-store")
+		w.Header().Set("Expires", "0")
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	policy := CachePolicy{Name: "test", Rules: []CacheRule{
+		{Match: "/routes", TTL: time.Hour},
+		{Match: "/fares", TTL: 0},
+	}}
+	cl := NewClient(nil, true, WithCachePolicy(policy))
+	for _, path := range []string{"/routes", "/routes", "/fares", "/fares", "/other"} {
+		sr, _, err := cl.Get(ctx, srv.URL+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := io.ReadAll(sr); string(b) != path {
+			t.Errorf("got %q, wanted %q", b, path)
+		}
+	}
+	// the second /routes is answered from the cache
+	if n := calls.Load(); n != 4 {
+		t.Errorf("got %d calls, wanted 4", n)
+	}
+	if _, _, err := NewClient(nil, true, WithCachePolicy(policy), WithoutCache()).Get(ctx, srv.URL+"/routes"); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 5 {
+		t.Errorf("got %d calls, wanted 5 without cache", n)
+	}
+
+	stats, err := CacheStats(root, "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Name != "test" || stats[0].Entries != 1 || stats[0].Stale != 0 || stats[0].Size == 0 {
+		t.Errorf("got %+v, wanted 1 fresh entry of test", stats)
+	}
+	if n, err := PruneCache(root, "test", time.Now()); n != 0 || err != nil {
+		t.Errorf("prune fresh: got %d (%+v), wanted 0", n, err)
+	}
+	if n, err := PruneCache(root, "", time.Now().Add(2*time.Hour)); n != 1 || err != nil {
+		t.Errorf("prune stale: got %d (%+v), wanted 1", n, err)
+	}
+
+	if _, _, err := cl.Get(ctx, srv.URL+"/routes"); err != nil {
+		t.Fatal(err)
+	}
+	// a file of the old, flat cache
+	if err := os.WriteFile(filepath.Join(root, "0123abcd"), []byte("HTTP/1.1 200 OK\r\n\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := CacheStats(root, "", time.Now()); err != nil || len(stats) == 0 || stats[0].Name != FlatCache || stats[0].Stale != 1 {
+		t.Errorf("got %+v (%+v), wanted the stale flat file first", stats, err)
+	}
+	for _, name := range []string{"..", "../x", "a/b"} {
+		if err := ClearCache(root, name); err == nil {
+			t.Errorf("%q: wanted error", name)
+		}
+	}
+	if err := ClearCache(root, ""); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := CacheStats(root, "", time.Now()); len(stats) != 0 || err != nil {
+		t.Errorf("got %+v (%+v) after clear, wanted none", stats, err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"net/http/cookiejar"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -29,32 +30,49 @@ type HTTPClient struct {
 	prepare func(*http.Request)
 }
 
-func NewClient(client *http.Client, cache bool) HTTPClient {
+// NewClient returns a HTTPClient using a copy of client (http.DefaultClient if nil).
+//
+// The requests (which are not answered from the cache) are limited
+// by the DefaultClientOptions, then by opts.
+//
+// If cache is true, the responses are cached on disk, under CacheDir,
+// for as long as the server allows, or as the CachePolicy (WithCachePolicy) says.
+//
+// With FLY_REPLAY=dir the responses are answered from the fixtures in dir
+// (a list separated by os.PathListSeparator), without any network access;
+// with FLY_RECORD=dir all the responses are recorded as fixtures into dir.
+func NewClient(client *http.Client, cache bool, opts ...ClientOption) HTTPClient {
 	if client == nil {
 		client = http.DefaultClient
 	}
-	if cache {
-		cacheDir, err := os.UserCacheDir()
-		if err != nil {
-			cacheDir = "/tmp"
+	cl := *client
+	if dirs := os.Getenv("FLY_REPLAY"); dirs != "" {
+		cl.Transport = NewReplayTransport(filepath.SplitList(dirs)...)
+		return HTTPClient{client: &cl}
+	}
+	var cfg clientConfig
+	for _, o := range append(slices.Clip(DefaultClientOptions), opts...) {
+		o(&cfg)
+	}
+	cl.Transport = cfg.limit(cl.Transport)
+	if cache && !cfg.noCache {
+		policy := CachePolicy{Name: "default"}
+		if cfg.policy != nil {
+			policy = *cfg.policy
 		}
-		cacheDir = filepath.Join(cacheDir, "airline")
-		client.Transport = httpcache.NewTransport(diskcache.New(cacheDir))
+		hct := httpcache.NewTransport(diskcache.New(filepath.Join(CacheDir(), policy.Name)))
+		hct.Transport = policy.transport(cl.Transport)
+		cl.Transport = hct
 	}
-	return HTTPClient{client: client}
+	if dir := os.Getenv("FLY_RECORD"); dir != "" {
+		cl.Transport = NewRecordTransport(dir, cl.Transport)
+	}
+	return HTTPClient{client: &cl}
 }
 func (c HTTPClient) SetJar(jar *cookiejar.Jar) HTTPClient {
 	cl := *c.client
-	hct := c.client.Transport.(*httpcache.Transport)
-	ht, ok := hct.Transport.(*http.Transport)
-	if ok {
-		ht = ht.Clone()
-	} else {
-		ht = http.DefaultTransport.(*http.Transport).Clone()
-	}
-	cl.Transport = &httpcache.Transport{Transport: ht, Cache: hct.Cache}
 	cl.Jar = jar
-	return HTTPClient{client: &cl}
+	return HTTPClient{client: &cl, prepare: c.prepare}
 }
 func (c HTTPClient) SetPrepare(f func(*http.Request)) HTTPClient {
 	return HTTPClient{client: c.client, prepare: f}
@@ -127,26 +145,38 @@ type withAllFares struct{ Airline }
 
 // AllFares returns all the fairs available from the given origin.
 func (co withAllFares) AllFares(ctx context.Context, origin string, departure time.Time, currency string) ([]Fare, error) {
-	destinations, err := co.Airline.Destinations(ctx, origin)
+	return eachDestination(ctx, co.Airline, origin, func(ctx context.Context, dest string) ([]Fare, error) {
+		return co.Fares(ctx, origin, dest, departure, currency)
+	})
+}
+
+// eachDestination returns the fares of all the destinations of origin, asking them concurrently,
+// and reports the fares of each destination to the partial function of the context (see Stream).
+func eachDestination(ctx context.Context, A Airline, origin string, fares func(ctx context.Context, destination string) ([]Fare, error)) ([]Fare, error) {
+	destinations, err := A.Destinations(ctx, origin)
 	if len(destinations) == 0 {
 		return nil, err
 	}
+	partial := ctxPartial(ctx)
 	grp, grpCtx := errgroup.WithContext(ctx)
 	grp.SetLimit(8)
 	var mu sync.Mutex
-	var fares []Fare
+	var all []Fare
 	for _, dest := range destinations {
 		dest := dest
 		grp.Go(func() error {
-			local, err := co.Fares(grpCtx, origin, dest, departure, currency)
+			local, err := fares(grpCtx, dest)
+			if partial != nil && len(local) != 0 {
+				partial(slices.Clip(local))
+			}
 			mu.Lock()
-			fares = append(fares, local...)
+			all = append(all, local...)
 			mu.Unlock()
 			return err
 		})
 	}
 	err = grp.Wait()
-	return fares, err
+	return all, err
 }
 
 type prepareCtx struct{}
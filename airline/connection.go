@@ -0,0 +1,245 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/tgulacsi/fly/iata"
+)
+
+// Connection is a self-transfer itinerary: fares chained by the traveller.
+type Connection struct {
+	Legs     []Fare        `json:"legs"`
+	Price    Money         `json:"price"`
+	Duration time.Duration `json:"duration"`
+}
+
+func (c Connection) Origin() string      { return c.Legs[0].Origin }
+func (c Connection) Destination() string { return c.Legs[len(c.Legs)-1].Destination }
+
+// Via returns the transfer airports.
+func (c Connection) Via() []string {
+	via := make([]string, 0, len(c.Legs)-1)
+	for _, f := range c.Legs[1:] {
+		via = append(via, f.Origin)
+	}
+	return via
+}
+
+// ConnectionOptions configures the connection builder.
+type ConnectionOptions struct {
+	// MinConnection is the minimal time between an arrival and the next departure at the same airport.
+	MinConnection time.Duration
+	// MinAirportChange is the minimal connection time when the next leg departs
+	// from a different airport of the same city.
+	MinAirportChange time.Duration
+	// MaxConnection is the maximal waiting time between two legs.
+	MaxConnection time.Duration
+	// MaxLegs is the maximal number of legs.
+	MaxLegs int
+	// City returns the city of the airport; the iata Municipality if nil.
+	City func(code string) string
+}
+
+// DefaultConnectionOptions is used for the zero fields of ConnectionOptions.
+var DefaultConnectionOptions = ConnectionOptions{
+	MinConnection:    3 * time.Hour,
+	MinAirportChange: 5 * time.Hour,
+	MaxConnection:    24 * time.Hour,
+	MaxLegs:          2,
+}
+
+func (opts ConnectionOptions) withDefaults() ConnectionOptions {
+	if opts.MinConnection == 0 {
+		opts.MinConnection = DefaultConnectionOptions.MinConnection
+	}
+	if opts.MinAirportChange == 0 {
+		opts.MinAirportChange = max(opts.MinConnection, DefaultConnectionOptions.MinAirportChange)
+	}
+	if opts.MaxConnection == 0 {
+		opts.MaxConnection = DefaultConnectionOptions.MaxConnection
+	}
+	if opts.MaxLegs == 0 {
+		opts.MaxLegs = DefaultConnectionOptions.MaxLegs
+	}
+	if opts.City == nil {
+		opts.City = func(code string) string { return iata.Get(code).Municipality }
+	}
+	return opts
+}
+
+// BuildConnections chains the fares into connections from origin to destination,
+// cheapest and shortest first.
+//
+// A fare can follow another if it departs from the same airport, or from another airport of the same city,
+// at least MinConnection (MinAirportChange) and at most MaxConnection after the arrival.
+// Fares without arrival time can only be the last leg.
+func BuildConnections(fares []Fare, origin, destination string, opts ConnectionOptions) []Connection {
+	opts = opts.withDefaults()
+	byOrigin := make(map[string][]Fare)
+	for _, f := range fares {
+		if f.Departure.IsZero() {
+			continue
+		}
+		f.Departure = Zoned(f.Departure, f.Origin)
+		if !f.Arrival.IsZero() {
+			f.Arrival = Zoned(f.Arrival, f.Destination)
+		}
+		byOrigin[f.Origin] = append(byOrigin[f.Origin], f)
+	}
+	cities := make(map[string][]string)
+	for code := range byOrigin {
+		if city := opts.City(code); city != "" {
+			cities[city] = append(cities[city], code)
+		}
+	}
+	nextAirports := func(code string) []string {
+		if city := opts.City(code); city != "" && len(cities[city]) != 0 {
+			return cities[city]
+		}
+		return []string{code}
+	}
+
+	var conns []Connection
+	var walk func(legs []Fare)
+	walk = func(legs []Fare) {
+		last := legs[len(legs)-1]
+		if last.Destination == destination {
+			if len(legs) > 1 {
+				conns = append(conns, newConnection(legs))
+			}
+			return
+		}
+		if len(legs) >= opts.MaxLegs || last.Arrival.IsZero() {
+			return
+		}
+		for _, code := range nextAirports(last.Destination) {
+			minConn := opts.MinConnection
+			if code != last.Destination {
+				minConn = opts.MinAirportChange
+			}
+			for _, f := range byOrigin[code] {
+				if f.Price.Currency != last.Price.Currency || visited(legs, f.Destination) {
+					continue
+				}
+				if wait := f.Departure.Sub(last.Arrival); wait < minConn || wait > opts.MaxConnection {
+					continue
+				}
+				walk(append(legs[:len(legs):len(legs)], f))
+			}
+		}
+	}
+	for _, f := range byOrigin[origin] {
+		walk([]Fare{f})
+	}
+	slices.SortStableFunc(conns, CmpConnection)
+	return conns
+}
+
+func visited(legs []Fare, code string) bool {
+	for _, f := range legs {
+		if f.Origin == code || f.Destination == code {
+			return true
+		}
+	}
+	return false
+}
+
+func newConnection(legs []Fare) Connection {
+	c := Connection{Legs: legs}
+	for _, f := range legs {
+		// the legs are of the same currency
+		c.Price, _ = c.Price.Add(f.Price)
+	}
+	last := legs[len(legs)-1]
+	end := last.Arrival
+	if end.IsZero() {
+		end = last.Departure
+	}
+	c.Duration = end.Sub(legs[0].Departure)
+	return c
+}
+
+// CmpConnection orders the connections by total price, then by total travel time.
+func CmpConnection(a, b Connection) int {
+	return cmp.Or(
+		a.Price.Cmp(b.Price),
+		cmp.Compare(a.Duration, b.Duration),
+		cmp.Compare(len(a.Legs), len(b.Legs)),
+	)
+}
+
+// Zoned returns t in the time zone of the airport,
+// if t is in UTC or Local (the source did not know the time zone).
+func Zoned(t time.Time, code string) time.Time {
+	if loc := t.Location(); loc != time.UTC && loc != time.Local {
+		return t
+	}
+	loc := iata.Get(code).Location
+	if loc == nil {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// Connections searches the fares of the airlines from origin to destination in the date range,
+// directly and through each airport reachable from origin,
+// and builds the self-transfer connections from them.
+func Connections(ctx context.Context, airlines map[string]Airline, origin, destination string, dr DateRange, currency string, opts ConnectionOptions) ([]Connection, error) {
+	var mu sync.Mutex
+	var fares []Fare
+	var errs []error
+	add := func(name string, local []Fare, err error) {
+		mu.Lock()
+		fares = append(fares, local...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		mu.Unlock()
+	}
+
+	var grp errgroup.Group
+	for name, A := range airlines {
+		name, A := name, A
+		grp.Go(func() error {
+			local, err := AllFaresInRange(ctx, A, origin, dr, currency)
+			add(name, local, err)
+			return nil
+		})
+	}
+	grp.Wait()
+
+	hubs := make(map[string]struct{})
+	for _, f := range fares {
+		if f.Destination != destination && f.Destination != origin {
+			hubs[f.Destination] = struct{}{}
+		}
+	}
+	// the second leg may depart the next day
+	next := DateRange{From: dr.From, To: dr.To.AddDate(0, 0, 1)}
+	grp.SetLimit(8)
+	for hub := range hubs {
+		for name, A := range airlines {
+			hub, name, A := hub, name, A
+			grp.Go(func() error {
+				local, err := FaresInRange(ctx, A, hub, destination, next, currency)
+				add(name, local, err)
+				return nil
+			})
+		}
+	}
+	grp.Wait()
+
+	return BuildConnections(fares, origin, destination, opts), errors.Join(errs...)
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildConnections(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	budapest, _ := time.LoadLocation("Europe/Budapest")
+	lisbon, _ := time.LoadLocation("Europe/Lisbon")
+	at := func(loc *time.Location, h, m int) time.Time { return time.Date(2024, 10, 1, h, m, 0, 0, loc) }
+	fare := func(orig, dest string, dep, arr time.Time, price float64) Fare {
+		return Fare{
+			Origin: orig, Destination: dest, Departure: dep, Arrival: arr,
+			Day: dep.Format("2006-01-02"), Price: NewMoney(price, "EUR"),
+		}
+	}
+	fares := []Fare{
+		fare("BUD", "STN", at(budapest, 6, 0), at(london, 7, 30), 20),
+		fare("BUD", "LGW", at(budapest, 8, 0), at(london, 9, 30), 10),
+		fare("BUD", "LGW", at(budapest, 10, 0), at(london, 11, 30), 15), // too late
+		fare("STN", "LIS", at(london, 11, 0), at(lisbon, 14, 0), 30),    // 3.5h at STN
+		fare("STN", "LIS", at(london, 9, 0), at(lisbon, 12, 0), 5),      // too short
+		fare("LGW", "LIS", at(london, 13, 0), at(lisbon, 16, 0), 40),    // 3.5h at LGW
+		fare("BUD", "LIS", at(budapest, 10, 0), at(lisbon, 13, 0), 100),
+	}
+	city := func(code string) string {
+		switch code {
+		case "STN", "LGW":
+			return "London"
+		}
+		return code
+	}
+	conns := BuildConnections(fares, "BUD", "LIS", ConnectionOptions{
+		MinConnection: 2 * time.Hour, MinAirportChange: 5 * time.Hour, City: city,
+	})
+	if len(conns) != 3 {
+		t.Fatalf("got %d connections, wanted 3: %+v", len(conns), conns)
+	}
+	for i, want := range []struct {
+		Price float64
+		Via   string
+	}{{50, "STN"}, {50, "LGW"}, {60, "LGW"}} {
+		if c := conns[i]; c.Price != NewMoney(want.Price, "EUR") || c.Via()[0] != want.Via {
+			t.Errorf("%d. got %v via %v, wanted %v via %s", i, c.Price, c.Via(), want.Price, want.Via)
+		}
+	}
+	if d := conns[0].Duration; d != 9*time.Hour {
+		t.Errorf("got duration %s, wanted 9h", d)
+	}
+	// BUD-STN, LGW-LIS is an airport change with 5.5h
+	if c := conns[2]; c.Legs[0].Destination != "STN" || c.Duration != 11*time.Hour {
+		t.Errorf("got %+v, wanted STN-LGW airport change", c)
+	}
+}
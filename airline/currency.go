@@ -0,0 +1,255 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/apd/v3"
+	"github.com/tgulacsi/mnbarf/mnb"
+)
+
+var apdCtx = apd.BaseContext.WithPrecision(16)
+
+// Converter converts amounts between currencies.
+type Converter interface {
+	Convert(ctx context.Context, amount Money, to string) (Money, error)
+}
+
+// Rates is a fixed rate table: the value of one unit of each currency in Base.
+type Rates struct {
+	Rates map[string]apd.Decimal
+	Base  string
+}
+
+var _ Converter = Rates{}
+
+// NewRates returns a fixed rate table from the value of one unit of each currency in base.
+func NewRates(base string, rates map[string]float64) Rates {
+	R := Rates{Base: base, Rates: make(map[string]apd.Decimal, len(rates))}
+	for k, v := range rates {
+		var d apd.Decimal
+		d.SetFloat64(v)
+		R.Rates[k] = d
+	}
+	return R
+}
+
+func (R Rates) rate(curr string) (*apd.Decimal, error) {
+	if curr == R.Base {
+		return apd.New(1, 0), nil
+	}
+	if r, ok := R.Rates[curr]; ok && !r.IsZero() {
+		return &r, nil
+	}
+	return nil, fmt.Errorf("%s: %w", curr, ErrUnknownCurrency)
+}
+
+// Convert the amount to the other currency.
+func (R Rates) Convert(ctx context.Context, amount Money, to string) (Money, error) {
+	if amount.Currency == to {
+		return amount, nil
+	}
+	rFrom, err := R.rate(amount.Currency)
+	if err != nil {
+		return amount, err
+	}
+	rTo, err := R.rate(to)
+	if err != nil {
+		return amount, err
+	}
+	v := amount.Decimal()
+	c := apd.MakeErrDecimal(apdCtx)
+	c.Mul(v, v, rFrom)
+	c.Quo(v, v, rTo)
+	if err = c.Err(); err != nil {
+		return amount, err
+	}
+	return moneyFromDecimal(v, to)
+}
+
+// ErrUnknownCurrency is returned for currencies without known rate.
+var ErrUnknownCurrency = fmt.Errorf("unknown currency")
+
+// NewMNBConverter returns a Converter using the current exchange rates of the Hungarian National Bank.
+//
+// The rates are downloaded at the first use.
+func NewMNBConverter(logger *slog.Logger) Converter {
+	return &lazyRates{load: func(ctx context.Context) (Rates, error) {
+		wsC := mnb.NewMNBArfolyamService("", nil, logger.With("lib", "mnb"))
+		dayRates, err := wsC.GetCurrentExchangeRates(ctx)
+		if err != nil {
+			return Rates{}, err
+		}
+		R := Rates{Base: "HUF", Rates: make(map[string]apd.Decimal, len(dayRates.Rates))}
+		for _, r := range dayRates.Rates {
+			d := r.Rate.Decimal
+			if r.Unit != 0 && r.Unit != 1 {
+				apdCtx.Quo(d, d, apd.NewWithBigInt(apd.NewBigInt(int64(r.Unit)), 0))
+			}
+			R.Rates[r.Currency] = *d
+		}
+		return R, nil
+	}}
+}
+
+const ecbURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+
+// NewECBConverter returns a Converter using the daily reference rates of the European Central Bank.
+//
+// The rates are downloaded at the first use.
+func NewECBConverter(client HTTPClient) Converter {
+	return &lazyRates{load: func(ctx context.Context) (Rates, error) {
+		sr, _, err := client.Get(ctx, ecbURL)
+		if err != nil {
+			return Rates{}, err
+		}
+		return ParseECBRates(sr)
+	}}
+}
+
+/*
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time='2024-08-30'>
+			<Cube currency='USD' rate='1.1087'/>
+			<Cube currency='HUF' rate='393.78'/>
+		</Cube>
+	</Cube>
+
+</gesmes:Envelope>
+*/
+
+// ParseECBRates parses the ECB eurofxref XML.
+func ParseECBRates(r io.Reader) (Rates, error) {
+	var env struct {
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube>Cube>Cube"`
+	}
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return Rates{}, fmt.Errorf("parse ECB rates: %w", err)
+	}
+	R := Rates{Base: "EUR", Rates: make(map[string]apd.Decimal, len(env.Rates))}
+	for _, r := range env.Rates {
+		d, _, err := apd.NewFromString(r.Rate)
+		if err != nil {
+			return R, fmt.Errorf("parse %s rate %q: %w", r.Currency, r.Rate, err)
+		}
+		// the ECB publishes the currency units for 1 EUR
+		var v apd.Decimal
+		if _, err := apdCtx.Quo(&v, apd.New(1, 0), d); err != nil {
+			return R, err
+		}
+		R.Rates[r.Currency] = v
+	}
+	return R, nil
+}
+
+// LoadRates reads a static rate table from a JSON file, such as
+//
+//	{"base":"EUR","rates":{"HUF":"0.00253","USD":"0.9"}}
+//
+// where each rate is the value of one unit of the currency in base.
+func LoadRates(fn string) (Rates, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return Rates{}, err
+	}
+	var file struct {
+		Base  string                 `json:"base"`
+		Rates map[string]json.Number `json:"rates"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return Rates{}, fmt.Errorf("parse %q: %w", fn, err)
+	}
+	R := Rates{Base: strings.ToUpper(file.Base), Rates: make(map[string]apd.Decimal, len(file.Rates))}
+	for k, v := range file.Rates {
+		d, _, err := apd.NewFromString(v.String())
+		if err != nil {
+			return R, fmt.Errorf("parse %s rate %q: %w", k, v, err)
+		}
+		R.Rates[strings.ToUpper(k)] = *d
+	}
+	return R, nil
+}
+
+// lazyRates loads the rates at the first use, and again after a failed load.
+type lazyRates struct {
+	load   func(context.Context) (Rates, error)
+	rates  Rates
+	loaded bool
+	mu     sync.Mutex
+}
+
+// rateLoadTimeout limits the loading of the rates, as it is detached from the caller's cancellation.
+const rateLoadTimeout = time.Minute
+
+func (lr *lazyRates) Convert(ctx context.Context, amount Money, to string) (Money, error) {
+	if amount.Currency == to {
+		return amount, nil
+	}
+	lr.mu.Lock()
+	if !lr.loaded {
+		// a canceled caller must not break the later conversions
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rateLoadTimeout)
+		rates, err := lr.load(loadCtx)
+		cancel()
+		if err != nil {
+			lr.mu.Unlock()
+			return amount, err
+		}
+		lr.rates, lr.loaded = rates, true
+	}
+	rates := lr.rates
+	lr.mu.Unlock()
+	return rates.Convert(ctx, amount, to)
+}
+
+type converterCtx struct{}
+
+// WithConverter returns a context which carries the Converter.
+func WithConverter(ctx context.Context, conv Converter) context.Context {
+	return context.WithValue(ctx, converterCtx{}, conv)
+}
+
+// CtxConverter returns the Converter of the context, or nil.
+func CtxConverter(ctx context.Context) Converter {
+	conv, _ := ctx.Value(converterCtx{}).(Converter)
+	return conv
+}
+
+// ConvertFares converts the prices of the fares to the currency, using the Converter of the context.
+func ConvertFares(ctx context.Context, fares []Fare, currency string) ([]Fare, error) {
+	if currency == "" {
+		return fares, nil
+	}
+	for i, f := range fares {
+		var err error
+		// each price on its own: a merged fare may have them in different currencies
+		for _, m := range []*Money{&f.Price, &f.ReturnPrice, &f.MemberPrice, &f.OriginalPrice, &f.RegularPrice} {
+			if m.Currency == currency || m.Currency == "" {
+				continue
+			}
+			if *m, err = m.Convert(ctx, currency); err != nil {
+				return fares, err
+			}
+		}
+		fares[i] = f
+	}
+	return fares, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertFares(t *testing.T) {
+	ctx := WithConverter(context.Background(), NewRates("HUF", map[string]float64{"EUR": 400, "GBP": 500}))
+	fares, err := ConvertFares(ctx, []Fare{
+		{Price: NewMoney(20, "EUR")},
+		{Price: NewMoney(40, "GBP")},
+		{Price: NewMoney(8000, "HUF")},
+	}, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []float64{20, 50, 20} {
+		if f := fares[i]; f.Price.Currency != "EUR" || math.Abs(f.Price.Float64()-want) > 0.001 {
+			t.Errorf("%d. got %v, wanted %v EUR", i, f.Price, want)
+		}
+	}
+	// the other prices are converted even if the price is already in the currency
+	fares, err = ConvertFares(ctx, []Fare{{
+		Price: NewMoney(20, "EUR"), ReturnPrice: NewMoney(8000, "HUF"),
+		MemberPrice: NewMoney(16, "GBP"), RegularPrice: NewMoney(10, "EUR"),
+	}}, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := fares[0]; f.Price != NewMoney(20, "EUR") || f.ReturnPrice != NewMoney(20, "EUR") ||
+		f.MemberPrice != NewMoney(20, "EUR") || f.RegularPrice != NewMoney(10, "EUR") || f.OriginalPrice != (Money{}) {
+		t.Errorf("got %+v, wanted every price in EUR", f)
+	}
+	if _, err = ConvertFares(ctx, []Fare{{Price: NewMoney(1, "XXX")}}, "EUR"); err == nil {
+		t.Error("wanted error for unknown currency")
+	}
+	if _, err = ConvertFares(context.Background(), []Fare{{Price: NewMoney(1, "HUF")}}, "EUR"); err == nil {
+		t.Error("wanted error without converter")
+	}
+}
+
+func TestParseECBRates(t *testing.T) {
+	R, err := ParseECBRates(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<gesmes:Sender><gesmes:name>European Central Bank</gesmes:name></gesmes:Sender>
+	<Cube>
+		<Cube time='2024-08-30'>
+			<Cube currency='USD' rate='1.1087'/>
+			<Cube currency='HUF' rate='400'/>
+			<Cube currency='GBP' rate='0.8'/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(R.Rates) != 3 {
+		t.Errorf("got %d rates, wanted 3", len(R.Rates))
+	}
+	ctx := context.Background()
+	if got, err := R.Convert(ctx, NewMoney(4000, "HUF"), "EUR"); err != nil || got != NewMoney(10, "EUR") {
+		t.Errorf("4000 HUF: got %v (%+v), wanted 10 EUR", got, err)
+	}
+	if got, err := R.Convert(ctx, NewMoney(8, "GBP"), "HUF"); err != nil || got != NewMoney(4000, "HUF") {
+		t.Errorf("8 GBP: got %v (%+v), wanted 4000 HUF", got, err)
+	}
+}
+
+func TestLoadRates(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(fn, []byte(`{"base":"eur","rates":{"huf":"0.0025"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	R, err := LoadRates(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := R.Convert(context.Background(), NewMoney(10, "EUR"), "HUF"); err != nil || got != NewMoney(4000, "HUF") {
+		t.Errorf("10 EUR: got %v (%+v), wanted 4000 HUF", got, err)
+	}
+}
+
+func TestLazyRates(t *testing.T) {
+	var loads int
+	lr := &lazyRates{load: func(ctx context.Context) (Rates, error) {
+		loads++
+		if err := ctx.Err(); err != nil {
+			return Rates{}, err
+		}
+		if loads == 1 {
+			return Rates{}, errors.New("temporary")
+		}
+		return NewRates("HUF", map[string]float64{"EUR": 400}), nil
+	}}
+	// the failed load is retried
+	if _, err := lr.Convert(context.Background(), NewMoney(400, "HUF"), "EUR"); err == nil {
+		t.Fatal("wanted the error of the first load")
+	}
+	// the cancellation of the caller does not reach the load
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if m, err := lr.Convert(canceled, NewMoney(400, "HUF"), "EUR"); err != nil || m != NewMoney(1, "EUR") {
+		t.Fatalf("got %v (%+v), wanted 1 EUR", m, err)
+	}
+	if _, err := lr.Convert(context.Background(), NewMoney(800, "HUF"), "EUR"); err != nil || loads != 2 {
+		t.Errorf("got %+v after %d loads, wanted 2 loads", err, loads)
+	}
+}
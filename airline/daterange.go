@@ -0,0 +1,140 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DateRange is an inclusive range of days.
+type DateRange struct {
+	From, To time.Time
+}
+
+// Day returns a DateRange of only one day.
+func Day(t time.Time) DateRange { return DateRange{From: t, To: t} }
+
+// Flex returns the DateRange of the days around t.
+func Flex(t time.Time, days int) DateRange {
+	return DateRange{From: t.AddDate(0, 0, -days), To: t.AddDate(0, 0, days)}
+}
+
+// IsZero reports whether the range is unset.
+func (dr DateRange) IsZero() bool { return dr.From.IsZero() && dr.To.IsZero() }
+
+// Days returns each day of the range.
+func (dr DateRange) Days() []time.Time {
+	var days []time.Time
+	for d := dr.From; !d.After(dr.To); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+// Contains reports whether the day (in 2006-01-02 format) is in the range.
+func (dr DateRange) Contains(day string) bool {
+	return dr.From.Format("2006-01-02") <= day && day <= dr.To.Format("2006-01-02")
+}
+
+// Filter returns the fares whose Day is in the range.
+func (dr DateRange) Filter(fares []Fare) []Fare {
+	ff := fares[:0]
+	for _, f := range fares {
+		if dr.Contains(f.Day) {
+			ff = append(ff, f)
+		}
+	}
+	return ff
+}
+
+// Spanner is implemented by the sources which return fares for more than one day
+// for a query with one departure day.
+type Spanner interface {
+	// Span returns the days covered by a query for the departure day.
+	Span(departure time.Time) DateRange
+}
+
+// Ranger is implemented by the sources which can query a date range at once.
+type Ranger interface {
+	// FaresInRange returns the fares from origin to destination in the date range.
+	FaresInRange(ctx context.Context, origin, destination string, dr DateRange, currency string) ([]Fare, error)
+}
+
+// AllRanger is implemented by the sources which query all the destinations of a date range
+// better than day by day.
+type AllRanger interface {
+	// AllFaresInRange returns the fares from origin to all its destinations in the date range.
+	AllFaresInRange(ctx context.Context, origin string, dr DateRange, currency string) ([]Fare, error)
+}
+
+// FaresInRange returns the fares of the airline from origin to destination in the date range.
+//
+// The range is queried at once if the source implements Ranger,
+// else each query of the source is done only once, if the source implements Spanner.
+func FaresInRange(ctx context.Context, A Airline, origin, destination string, dr DateRange, currency string) ([]Fare, error) {
+	if r, ok := A.(Ranger); ok {
+		fares, err := r.FaresInRange(ctx, origin, destination, dr, currency)
+		return dr.Filter(fares), err
+	}
+	return searchRange(A, dr, func(day time.Time) ([]Fare, error) {
+		return A.Fares(ctx, origin, destination, day, currency)
+	})
+}
+
+// AllFaresInRange returns all the fares of the airline from origin in the date range.
+//
+// An AllRanger source is asked for the range at once.
+// Without its own AllFares, a Ranger source is asked for the range of each destination;
+// else each query of the source is done only once, if the source implements Spanner.
+func AllFaresInRange(ctx context.Context, A Airline, origin string, dr DateRange, currency string) ([]Fare, error) {
+	if r, ok := A.(AllRanger); ok {
+		fares, err := r.AllFaresInRange(ctx, origin, dr, currency)
+		return dr.Filter(fares), err
+	}
+	if r, ok := A.(Ranger); ok {
+		if _, ok := A.(AirlineAllFares); !ok {
+			fares, err := eachDestination(ctx, A, origin, func(ctx context.Context, dest string) ([]Fare, error) {
+				return r.FaresInRange(ctx, origin, dest, dr, currency)
+			})
+			return dr.Filter(fares), err
+		}
+	}
+	all := WithAllFares(A)
+	return searchRange(A, dr, func(day time.Time) ([]Fare, error) {
+		return all.AllFares(ctx, origin, day, currency)
+	})
+}
+
+func searchRange(A Airline, dr DateRange, search func(time.Time) ([]Fare, error)) ([]Fare, error) {
+	spanner, _ := A.(Spanner)
+	var fares []Fare
+	var errs []error
+	for day := dr.From; !day.After(dr.To); {
+		query := day
+		if spanner != nil {
+			// query the latest day which still covers day
+			if from := spanner.Span(day).From; from.Before(day) {
+				if q := day.Add(day.Sub(from)); !spanner.Span(q).From.After(day) {
+					query = q
+				}
+			}
+		}
+		local, err := search(query)
+		fares = append(fares, local...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		next := day.AddDate(0, 0, 1)
+		if spanner != nil {
+			if to := spanner.Span(query).To; !to.Before(next) {
+				next = to.AddDate(0, 0, 1)
+			}
+		}
+		day = next
+	}
+	return dr.Filter(fares), errors.Join(errs...)
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type monthly struct{ queries []time.Time }
+
+func (m *monthly) Destinations(ctx context.Context, origin string) ([]string, error) {
+	return []string{"STN"}, nil
+}
+func (m *monthly) Fares(ctx context.Context, origin, destination string, departure time.Time, currency string) ([]Fare, error) {
+	m.queries = append(m.queries, departure)
+	var fares []Fare
+	for _, d := range m.Span(departure).Days() {
+		fares = append(fares, Fare{Origin: origin, Destination: destination, Day: d.Format("2006-01-02")})
+	}
+	return fares, nil
+}
+func (m *monthly) Span(departure time.Time) DateRange {
+	first := time.Date(departure.Year(), departure.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return DateRange{From: first, To: first.AddDate(0, 1, -1)}
+}
+
+func TestFaresInRange(t *testing.T) {
+	m := new(monthly)
+	dr := DateRange{
+		From: time.Date(2024, 10, 20, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 11, 3, 0, 0, 0, 0, time.UTC),
+	}
+	fares, err := FaresInRange(context.Background(), m, "BUD", "STN", dr, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.queries) != 2 {
+		t.Errorf("got %d queries (%v), wanted 2", len(m.queries), m.queries)
+	}
+	if len(fares) != 15 {
+		t.Errorf("got %d fares, wanted 15", len(fares))
+	}
+	for _, f := range fares {
+		if !dr.Contains(f.Day) {
+			t.Errorf("%s is out of range", f.Day)
+		}
+	}
+}
+
+// ranged queries the whole range at once.
+type ranged struct {
+	monthly
+	ranges []DateRange
+}
+
+func (r *ranged) FaresInRange(ctx context.Context, origin, destination string, dr DateRange, currency string) ([]Fare, error) {
+	r.ranges = append(r.ranges, dr)
+	var fares []Fare
+	for _, d := range (DateRange{From: dr.From.AddDate(0, 0, -1), To: dr.To.AddDate(0, 0, 1)}).Days() {
+		fares = append(fares, Fare{Origin: origin, Destination: destination, Day: d.Format("2006-01-02")})
+	}
+	return fares, nil
+}
+
+func TestRanger(t *testing.T) {
+	r := new(ranged)
+	dr := DateRange{
+		From: time.Date(2024, 10, 20, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 11, 3, 0, 0, 0, 0, time.UTC),
+	}
+	fares, err := FaresInRange(context.Background(), r, "BUD", "STN", dr, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := AllFaresInRange(context.Background(), r, "BUD", dr, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.ranges) != 2 || r.ranges[0] != dr || r.ranges[1] != dr || len(r.queries) != 0 {
+		t.Errorf("got ranges %v and queries %v, wanted the range twice", r.ranges, r.queries)
+	}
+	// trimmed to the range
+	if len(fares) != 15 || len(all) != 15 {
+		t.Errorf("got %d and %d fares, wanted 15", len(fares), len(all))
+	}
+}
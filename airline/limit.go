@@ -0,0 +1,197 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ClientOption configures the HTTPClient returned by NewClient.
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	limitTransport
+	policy  *CachePolicy
+	noCache bool
+}
+
+// DefaultClientOptions are applied by NewClient before its own options.
+//
+// The limits of an option value are shared by all the clients configured with it,
+// so setting them here (before the sources are opened) makes them global.
+var DefaultClientOptions = []ClientOption{
+	WithMaxConcurrent(16),
+	WithRateLimit(5, 10),
+	WithRetry(3, time.Second),
+}
+
+// WithMaxConcurrent caps the number of the concurrent requests (of all hosts) at n,
+// unlimited if n <= 0.
+//
+// The cap is shared by all the clients configured with the returned option.
+func WithMaxConcurrent(n int) ClientOption {
+	var sem chan struct{}
+	if n > 0 {
+		sem = make(chan struct{}, n)
+	}
+	return func(cfg *clientConfig) { cfg.sem = sem }
+}
+
+// WithRateLimit limits the requests to perSecond (a token bucket of burst size) per host,
+// unlimited if perSecond <= 0.
+//
+// The buckets are shared by all the clients configured with the returned option.
+func WithRateLimit(perSecond float64, burst int) ClientOption {
+	var hl *hostLimiter
+	if perSecond > 0 {
+		hl = &hostLimiter{limit: rate.Limit(perSecond), burst: max(burst, 1)}
+	}
+	return func(cfg *clientConfig) { cfg.hosts = hl }
+}
+
+// WithRetry retries the throttled (429 Too Many Requests, 503 Service Unavailable)
+// and the failed (network error, 502, 504) requests at most retries times.
+//
+// The wait is the Retry-After of the response, or backoff doubled after each try.
+func WithRetry(retries int, backoff time.Duration) ClientOption {
+	return func(cfg *clientConfig) { cfg.retries, cfg.backoff = retries, backoff }
+}
+
+// maxBackoff is the longest wait between two tries.
+const maxBackoff = time.Minute
+
+type limitTransport struct {
+	base    http.RoundTripper
+	sem     chan struct{}
+	hosts   *hostLimiter
+	retries int
+	backoff time.Duration
+}
+
+// limit returns base (http.DefaultTransport if nil) limited with the options,
+// or base itself if the options do not limit anything.
+func (cfg clientConfig) limit(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if cfg.sem == nil && cfg.hosts == nil && cfg.retries <= 0 {
+		return base
+	}
+	lt := cfg.limitTransport
+	lt.base = base
+	return &lt
+}
+
+func (lt *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for try := 0; ; try++ {
+		resp, err := lt.roundTrip(req)
+		if try >= lt.retries || !retriable(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			// cannot rewind the body
+			return resp, err
+		}
+		wait := min(lt.backoff<<try, maxBackoff)
+		if resp != nil {
+			if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				wait = min(d, maxBackoff)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		CtxLogger(ctx).Debug("retry", "url", req.URL.String(), "try", try+1, "wait", wait, "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+func (lt *limitTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if lt.hosts != nil {
+		if err := lt.hosts.get(req.URL.Host).Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if lt.sem != nil {
+		select {
+		case lt.sem <- struct{}{}:
+			defer func() { <-lt.sem }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return lt.base.RoundTrip(req)
+}
+
+func retriable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable,
+		http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header: delay in seconds, or a HTTP date.
+func retryAfter(s string, now time.Time) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// hostLimiter holds a token bucket per host.
+type hostLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rate.Limiter
+	limit   rate.Limit
+	burst   int
+}
+
+func (hl *hostLimiter) get(host string) *rate.Limiter {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	if hl.buckets == nil {
+		hl.buckets = make(map[string]*rate.Limiter)
+	}
+	l := hl.buckets[host]
+	if l == nil {
+		l = rate.NewLimiter(hl.limit, hl.burst)
+		hl.buckets[host] = l
+	}
+	return l
+}
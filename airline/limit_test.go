@@ -0,0 +1,103 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		io.WriteString(w, string(b))
+	}))
+	defer srv.Close()
+
+	cl := NewClient(nil, false, WithRetry(2, time.Hour))
+	sr, _, err := cl.Post(context.Background(), srv.URL, strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(sr); string(b) != "body" {
+		t.Errorf("got %q, wanted the body resent", b)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("got %d calls, wanted 2", n)
+	}
+
+	calls.Store(0)
+	cl = NewClient(nil, false, WithRetry(0, 0))
+	if _, resp, err := cl.Get(context.Background(), srv.URL); err == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("got %+v, wanted 429 without retry", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		In   string
+		Want time.Duration
+		OK   bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"Tue, 01 Oct 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"soon", 0, false},
+	} {
+		if got, ok := retryAfter(tc.In, now); got != tc.Want || ok != tc.OK {
+			t.Errorf("%q: got %v, %t, wanted %v, %t", tc.In, got, ok, tc.Want, tc.OK)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for m := maxInFlight.Load(); n > m && !maxInFlight.CompareAndSwap(m, n); m = maxInFlight.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	// two clients with the same options share the limits
+	opts := []ClientOption{WithMaxConcurrent(2), WithRateLimit(100, 1), WithRetry(0, 0)}
+	clients := []HTTPClient{NewClient(nil, false, opts...), NewClient(nil, false, opts...)}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		cl := clients[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := cl.Get(context.Background(), srv.URL); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := maxInFlight.Load(); n > 2 {
+		t.Errorf("got %d concurrent requests, wanted at most 2", n)
+	}
+	// 10 requests with 100/s, burst 1
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Errorf("10 requests took %s, wanted at least 80ms", d)
+	}
+}
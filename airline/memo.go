@@ -0,0 +1,108 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Memo remembers the results of a lookup for TTL, and coalesces
+// the concurrent lookups of the same key into one call.
+//
+// The zero value is usable: it only coalesces.
+// The values are shared between the callers, so they must not be modified.
+type Memo[K comparable, V any] struct {
+	entries map[K]*memoEntry[V]
+	TTL     time.Duration
+	mu      sync.Mutex
+}
+
+type memoEntry[V any] struct {
+	expires time.Time
+	value   V
+	err     error
+	done    chan struct{}
+	// waiters is the number of the calls which joined the fetch in flight.
+	waiters int
+}
+
+// Do returns the remembered value of key, or calls fetch to look it up.
+//
+// The concurrent calls with the same key wait for the first one, and get its result.
+// Errors are not remembered: the next call after a failed lookup tries again.
+// A panic of fetch is returned as an error to the waiting calls, and passed on to the caller.
+func (m *Memo[K, V]) Do(ctx context.Context, key K, fetch func(context.Context) (V, error)) (V, error) {
+	m.mu.Lock()
+	if m.entries == nil {
+		m.entries = make(map[K]*memoEntry[V])
+	}
+	e := m.entries[key]
+	if e != nil {
+		select {
+		case <-e.done:
+			if e.err != nil || time.Now().After(e.expires) {
+				e = nil
+			}
+		default: // in flight
+			e.waiters++
+		}
+	}
+	if e != nil {
+		m.mu.Unlock()
+		select {
+		case <-e.done:
+			return e.value, e.err
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+	}
+	m.evict(time.Now())
+	e = &memoEntry[V]{done: make(chan struct{})}
+	m.entries[key] = e
+	m.mu.Unlock()
+
+	defer func() {
+		e.expires = time.Now().Add(m.TTL)
+		if r := recover(); r != nil {
+			// do not leave the waiters hanging
+			e.err = fmt.Errorf("memo fetch panicked: %v", r)
+			close(e.done)
+			panic(r)
+		}
+		close(e.done)
+	}()
+	e.value, e.err = fetch(ctx)
+	return e.value, e.err
+}
+
+// evict deletes the finished entries which are expired or failed; m.mu must be held.
+func (m *Memo[K, V]) evict(now time.Time) {
+	for k, e := range m.entries {
+		select {
+		case <-e.done:
+			if e.err != nil || now.After(e.expires) {
+				delete(m.entries, k)
+			}
+		default:
+		}
+	}
+}
+
+// ClientKey is a Memo key which tells the HTTPClients apart,
+// so the Memos of a package can be shared by its clients.
+type ClientKey[K comparable] struct {
+	client *http.Client
+	Key    K
+}
+
+// MemoKey returns the key of the response of client.
+func MemoKey[K comparable](client HTTPClient, key K) ClientKey[K] {
+	return ClientKey[K]{client: client.client, Key: key}
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemo(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	gate := make(chan struct{})
+	fetch := func(ctx context.Context) (int, error) {
+		<-gate
+		return int(calls.Add(1)), nil
+	}
+	m := Memo[string, int]{TTL: time.Hour}
+
+	// the concurrent lookups are coalesced
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := m.Do(ctx, "BUD", fetch); err != nil || v != 1 {
+				t.Errorf("got %d (%+v), wanted 1", v, err)
+			}
+		}()
+	}
+	close(gate)
+	wg.Wait()
+	// and remembered
+	if v, _ := m.Do(ctx, "BUD", fetch); v != 1 || calls.Load() != 1 {
+		t.Errorf("got %d after %d calls, wanted 1 after 1", v, calls.Load())
+	}
+	if v, _ := m.Do(ctx, "VIE", fetch); v != 2 {
+		t.Errorf("VIE: got %d, wanted 2", v)
+	}
+
+	// the errors are not remembered
+	errBad := errors.New("bad")
+	if _, err := m.Do(ctx, "BTS", func(context.Context) (int, error) { return 0, errBad }); !errors.Is(err, errBad) {
+		t.Errorf("got %+v, wanted %v", err, errBad)
+	}
+	if v, err := m.Do(ctx, "BTS", fetch); err != nil || v != 3 {
+		t.Errorf("BTS: got %d (%+v), wanted 3", v, err)
+	}
+
+	// without TTL, only the concurrent calls are coalesced
+	var nottl Memo[string, int]
+	nottl.Do(ctx, "BUD", fetch)
+	if v, _ := nottl.Do(ctx, "BUD", fetch); v != 5 {
+		t.Errorf("no TTL: got %d, wanted 5", v)
+	}
+}
+
+func TestMemoPanic(t *testing.T) {
+	ctx := context.Background()
+	m := Memo[string, int]{TTL: time.Hour}
+	gate := make(chan struct{})
+	waited := make(chan error)
+	go func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("wanted the panic of fetch")
+			}
+		}()
+		m.Do(ctx, "BUD", func(context.Context) (int, error) {
+			go func() {
+				_, err := m.Do(ctx, "BUD", func(context.Context) (int, error) { return 1, nil })
+				waited <- err
+			}()
+			<-gate
+			panic("boom")
+		})
+	}()
+	// release the first call only after the second one joined it
+	for joined := false; !joined; {
+		m.mu.Lock()
+		e := m.entries["BUD"]
+		joined = e != nil && e.waiters == 1
+		m.mu.Unlock()
+		runtime.Gosched()
+	}
+	close(gate)
+	if err := <-waited; err == nil {
+		t.Error("the waiting call got no error")
+	}
+	// the failed entry is evicted on the next miss
+	m.Do(ctx, "VIE", func(context.Context) (int, error) { return 2, nil })
+	m.mu.Lock()
+	_, ok := m.entries["BUD"]
+	m.mu.Unlock()
+	if ok {
+		t.Error("the failed entry is still there")
+	}
+}
+
+func TestMemoKey(t *testing.T) {
+	a, b := NewClient(nil, false), NewClient(nil, false)
+	if MemoKey(a, "BUD") == MemoKey(b, "BUD") || MemoKey(a, "BUD") != MemoKey(a, "BUD") {
+		t.Error("wanted the keys of different clients to differ")
+	}
+}
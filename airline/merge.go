@@ -0,0 +1,125 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MergeFares merges the fares of the same physical flight, reported by more sources (or more times).
+//
+// Two fares are of the same flight if they fly from the same origin to the same destination,
+// departing at the same time (to the minute), with the same carrier,
+// and with the same flight numbers, if both are known.
+// The cheapest is kept, completed with the flight numbers and segments of the others if it misses them,
+// and its Sources lists all the sources which reported the flight.
+//
+// The fares without departure time, or in different currencies, are not merged.
+// The order of the fares is kept.
+func MergeFares(fares []Fare) []Fare {
+	type flightKey struct {
+		Origin, Destination, Currency string
+		Departure                     time.Time
+	}
+	groups := make(map[flightKey][]int)
+	merged := make([]Fare, 0, len(fares))
+	for _, f := range fares {
+		f.Sources = appendSources(slices.Clip(f.Sources), f.Source)
+		if f.Departure.IsZero() {
+			merged = append(merged, f)
+			continue
+		}
+		k := flightKey{
+			Origin: f.Origin, Destination: f.Destination, Currency: f.Price.Currency,
+			Departure: f.Departure.UTC().Truncate(time.Minute),
+		}
+		i := slices.IndexFunc(groups[k], func(i int) bool { return sameFlight(merged[i], f) })
+		if i < 0 {
+			groups[k] = append(groups[k], len(merged))
+			merged = append(merged, f)
+			continue
+		}
+		i = groups[k][i]
+		merged[i] = mergeFare(merged[i], f)
+	}
+	return merged
+}
+
+func appendSources(sources []string, more ...string) []string {
+	for _, s := range more {
+		if s != "" && !slices.Contains(sources, s) {
+			sources = append(sources, s)
+		}
+	}
+	slices.Sort(sources)
+	return sources
+}
+
+// mergeFare returns the cheaper of the two fares of the same flight, with the sources of both.
+func mergeFare(a, b Fare) Fare {
+	if b.Price.Cmp(a.Price) < 0 {
+		a, b = b, a
+	}
+	a.Sources = appendSources(slices.Clip(a.Sources), b.Sources...)
+	if len(a.FlightNumbers) == 0 {
+		a.FlightNumbers = b.FlightNumbers
+	}
+	if len(a.Segments) < len(b.Segments) {
+		a.Segments = b.Segments
+	}
+	if a.Arrival.IsZero() {
+		a.Arrival = b.Arrival
+	}
+	a.Duration = cmp.Or(a.Duration, b.Duration)
+	if a.MemberPrice.IsZero() {
+		a.MemberPrice = b.MemberPrice
+	}
+	return a
+}
+
+// sameFlight reports whether the two fares (of the same route and departure) are of the same flight.
+func sameFlight(a, b Fare) bool {
+	if !sameCarrier(a.Airline, b.Airline) {
+		return false
+	}
+	if len(a.FlightNumbers) == 0 || len(b.FlightNumbers) == 0 {
+		return true
+	}
+	return slices.EqualFunc(a.FlightNumbers, b.FlightNumbers, func(x, y string) bool {
+		return normalizeFlightNumber(x) == normalizeFlightNumber(y)
+	})
+}
+
+// sameCarrier reports whether the two airline names are of the same carrier:
+// "Wizz Air" is the same as "Wizz Air Malta".
+func sameCarrier(a, b string) bool {
+	a, b = normalizeName(a), normalizeName(b)
+	if a == "" || b == "" {
+		return a == b
+	}
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+func normalizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// normalizeFlightNumber normalizes "W6 0201" and "W6201" to "W6201".
+func normalizeFlightNumber(s string) string {
+	s = strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	if len(s) > 2 {
+		s = s[:2] + strings.TrimLeft(s[2:], "0")
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMergeFares(t *testing.T) {
+	budapest, err := time.LoadLocation("Europe/Budapest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dep := time.Date(2024, 10, 20, 6, 0, 0, 0, budapest)
+	fares := MergeFares([]Fare{
+		{Airline: "Wizz Air", Source: "wizzair", Origin: "BUD", Destination: "LTN", Departure: dep, Price: NewMoney(30, "EUR"), MemberPrice: NewMoney(20, "EUR")},
+		{Airline: "Wizz Air Malta", Source: "gflights", Origin: "BUD", Destination: "LTN", Departure: dep.UTC().Add(20 * time.Second), Price: NewMoney(28, "EUR"),
+			FlightNumbers: []string{"W9 2201"}},
+		// another carrier at the same time
+		{Airline: "Ryanair", Source: "ryanair", Origin: "BUD", Destination: "LTN", Departure: dep, Price: NewMoney(25, "EUR")},
+		// the same carrier, but another flight number
+		{Airline: "Wizz Air", Source: "gflights", Origin: "BUD", Destination: "LTN", Departure: dep, Price: NewMoney(35, "EUR"),
+			FlightNumbers: []string{"W6 2203"}},
+		{Airline: "Wizz Air", Source: "wizzair", Origin: "BUD", Destination: "LTN", Departure: dep, Price: NewMoney(30, "EUR")},
+	})
+	if len(fares) != 3 {
+		t.Fatalf("got %d fares, wanted 3: %+v", len(fares), fares)
+	}
+	f := fares[0]
+	if f.Price != NewMoney(28, "EUR") || f.Source != "gflights" || !slices.Equal(f.Sources, []string{"gflights", "wizzair"}) {
+		t.Errorf("got %+v, wanted the cheapest with both sources", f)
+	}
+	if f.MemberPrice != NewMoney(20, "EUR") || !slices.Equal(f.FlightNumbers, []string{"W9 2201"}) {
+		t.Errorf("got %+v, wanted the member price and the flight number", f)
+	}
+	if f := fares[1]; f.Airline != "Ryanair" || !slices.Equal(f.Sources, []string{"ryanair"}) {
+		t.Errorf("got %+v", f)
+	}
+	if f := fares[2]; f.Price != NewMoney(35, "EUR") {
+		t.Errorf("got %+v, wanted W6 2203", f)
+	}
+}
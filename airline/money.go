@@ -0,0 +1,240 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// moneyDigits is the number of the fractional digits of Money.
+const moneyDigits = 4
+
+const moneyScale = 10_000
+
+// Money is an exact decimal amount (with 4 fractional digits) of a currency (ISO 4217 code).
+//
+// The zero value is zero, without currency.
+type Money struct {
+	Currency string
+	units    int64 // in 1/moneyScale of the currency
+}
+
+// ErrCurrencyMismatch is returned when adding amounts of different currencies.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// NewMoney returns the amount, rounded to 4 fractional digits, of the currency.
+func NewMoney(amount float64, currency string) Money {
+	return Money{Currency: currency, units: int64(math.Round(amount * moneyScale))}
+}
+
+// ParseMoney parses the decimal amount ("12.99") of the currency.
+func ParseMoney(amount, currency string) (Money, error) {
+	d, _, err := apd.NewFromString(strings.TrimSpace(amount))
+	if err != nil {
+		return Money{}, fmt.Errorf("parse amount %q: %w", amount, err)
+	}
+	return moneyFromDecimal(d, currency)
+}
+
+func moneyFromDecimal(d *apd.Decimal, currency string) (Money, error) {
+	var q apd.Decimal
+	if _, err := apdCtx.Quantize(&q, d, -moneyDigits); err != nil {
+		return Money{}, err
+	}
+	q.Exponent = 0
+	units, err := q.Int64()
+	return Money{Currency: currency, units: units}, err
+}
+
+// Decimal returns the amount as an apd.Decimal.
+func (m Money) Decimal() *apd.Decimal { return apd.New(m.units, -moneyDigits) }
+
+// Float64 returns the amount as a float64 - for display and statistics only.
+func (m Money) Float64() float64 { return float64(m.units) / moneyScale }
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool { return m.units == 0 }
+
+// Amount returns the decimal amount, with at least 2 fractional digits: "12.50".
+func (m Money) Amount() string {
+	u := m.units
+	var sign string
+	if u < 0 {
+		sign, u = "-", -u
+	}
+	frac := strconv.FormatInt(u%moneyScale+moneyScale, 10)[1:]
+	frac = strings.TrimRight(frac, "0")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	return sign + strconv.FormatInt(u/moneyScale, 10) + "." + frac
+}
+
+// String returns the amount and the currency: "12.50 EUR".
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Amount()
+	}
+	return m.Amount() + " " + m.Currency
+}
+
+// Format implements fmt.Formatter: the floating point verbs (%.2f) format the amount,
+// the others the String.
+func (m Money) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		fmt.Fprintf(s, fmt.FormatString(s, verb), m.Float64())
+	default:
+		fmt.Fprintf(s, fmt.FormatString(s, verb), m.String())
+	}
+}
+
+// Add returns the sum, the currencies must be the same.
+//
+// A zero Money without currency can be added to anything.
+func (m Money) Add(o Money) (Money, error) {
+	switch {
+	case o.Currency == "" && o.units == 0:
+		return m, nil
+	case m.Currency == "" && m.units == 0:
+		return o, nil
+	case m.Currency != o.Currency:
+		return m, fmt.Errorf("%s + %s: %w", m, o, ErrCurrencyMismatch)
+	}
+	m.units += o.units
+	return m, nil
+}
+
+// Sub returns the difference, the currencies must be the same.
+func (m Money) Sub(o Money) (Money, error) {
+	o.units = -o.units
+	return m.Add(o)
+}
+
+// Mul returns the amount multiplied by n.
+func (m Money) Mul(n int) Money {
+	m.units *= int64(n)
+	return m
+}
+
+// Percent returns the p percent of the amount, rounded to 4 fractional digits.
+func (m Money) Percent(p float64) Money {
+	m.units = int64(math.Round(float64(m.units) * p / 100))
+	return m
+}
+
+// Cmp compares the amounts.
+//
+// A Money without currency compares to any currency by its amount, but different currencies
+// are ordered by the currency code: the amounts have to be converted first to compare them.
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m.Currency != o.Currency && m.Currency != "" && o.Currency != "":
+		return strings.Compare(m.Currency, o.Currency)
+	case m.units < o.units:
+		return -1
+	case m.units > o.units:
+		return 1
+	}
+	return 0
+}
+
+// Sum returns the sum of the amounts, which must be in the same currency.
+func Sum(ms ...Money) (Money, error) {
+	var sum Money
+	for _, m := range ms {
+		var err error
+		if sum, err = sum.Add(m); err != nil {
+			return sum, err
+		}
+	}
+	return sum, nil
+}
+
+// Convert the amount to the currency, with the Converter of the context.
+func (m Money) Convert(ctx context.Context, currency string) (Money, error) {
+	if m.Currency == currency || currency == "" {
+		return m, nil
+	}
+	if m.IsZero() {
+		m.Currency = currency
+		return m, nil
+	}
+	conv := CtxConverter(ctx)
+	if conv == nil {
+		return m, fmt.Errorf("convert %s to %s: no converter", m.Currency, currency)
+	}
+	c, err := conv.Convert(ctx, m, currency)
+	if err != nil {
+		return m, fmt.Errorf("convert %s to %s: %w", m.Currency, currency, err)
+	}
+	return c, nil
+}
+
+// Over reports whether the amount is over the ceiling, after converting it to the currency
+// of the ceiling with the Converter of the context. Nothing is over a zero ceiling.
+func (m Money) Over(ctx context.Context, ceiling Money) (bool, error) {
+	if ceiling.IsZero() {
+		return false, nil
+	}
+	c, err := m.Convert(ctx, ceiling.Currency)
+	if err != nil {
+		return false, err
+	}
+	return c.Cmp(ceiling) > 0, nil
+}
+
+// MarshalJSON returns {"amount":12.5,"currency":"EUR"}, or null for the zero value.
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m == (Money{}) {
+		return []byte("null"), nil
+	}
+	cur, err := json.Marshal(m.Currency)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(`{"amount":` + m.Amount() + `,"currency":` + string(cur) + `}`), nil
+}
+
+// UnmarshalJSON accepts {"amount":12.5,"currency":"EUR"}, null or a bare number (without currency).
+func (m *Money) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+	if len(b) != 0 && b[0] != '{' {
+		var n json.Number
+		if err := json.Unmarshal(b, &n); err != nil {
+			return err
+		}
+		v, err := ParseMoney(n.String(), "")
+		*m = v
+		return err
+	}
+	var v struct {
+		Currency string      `json:"currency"`
+		Amount   json.Number `json:"amount"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v.Amount == "" {
+		*m = Money{Currency: v.Currency}
+		return nil
+	}
+	var err error
+	*m, err = ParseMoney(v.Amount.String(), v.Currency)
+	return err
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMoney(t *testing.T) {
+	for _, tc := range []struct {
+		In, Amount string
+	}{
+		{"12.99", "12.99"},
+		{"12.5", "12.50"},
+		{"7", "7.00"},
+		{"0.12345", "0.1235"},
+		{"-3.1", "-3.10"},
+	} {
+		m, err := ParseMoney(tc.In, "EUR")
+		if err != nil {
+			t.Fatalf("%q: %+v", tc.In, err)
+		}
+		if got := m.Amount(); got != tc.Amount {
+			t.Errorf("%q: got %q, wanted %q", tc.In, got, tc.Amount)
+		}
+	}
+	if _, err := ParseMoney("x", "EUR"); err == nil {
+		t.Error("wanted error for bad amount")
+	}
+
+	// 0.1 + 0.2 is not 0.3 with float64
+	sum, err := Sum(NewMoney(0.1, "EUR"), NewMoney(0.2, "EUR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := NewMoney(0.3, "EUR"); sum != want {
+		t.Errorf("got %v, wanted %v", sum, want)
+	}
+	if _, err := NewMoney(1, "EUR").Add(NewMoney(1, "HUF")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("got %+v, wanted ErrCurrencyMismatch", err)
+	}
+	if NewMoney(1, "HUF").Cmp(NewMoney(2, "EUR")) <= 0 || NewMoney(2, "EUR").Cmp(NewMoney(1, "")) <= 0 {
+		t.Error("cmp: wanted ordering by currency, then by amount")
+	}
+	if got := NewMoney(40, "EUR").Percent(25); got != NewMoney(10, "EUR") {
+		t.Errorf("percent: got %v, wanted 10 EUR", got)
+	}
+
+	m := NewMoney(12.5, "EUR")
+	if got := fmt.Sprintf("%s|%v|% 6.2f", m, m, m); got != "12.50 EUR|12.50 EUR| 12.50" {
+		t.Errorf("format: got %q", got)
+	}
+}
+
+func TestMoneyOver(t *testing.T) {
+	ctx := WithConverter(context.Background(), NewRates("HUF", map[string]float64{"EUR": 400}))
+	ceiling := NewMoney(16.5, "EUR")
+	for _, tc := range []struct {
+		Price Money
+		Over  bool
+	}{
+		{NewMoney(16.5, "EUR"), false},
+		{NewMoney(17, "EUR"), true},
+		// 15 and 20 EUR, not 6000 and 8000 "EUR"
+		{NewMoney(6000, "HUF"), false},
+		{NewMoney(8000, "HUF"), true},
+	} {
+		if over, err := tc.Price.Over(ctx, ceiling); err != nil || over != tc.Over {
+			t.Errorf("%v: got %t (%+v), wanted %t", tc.Price, over, err, tc.Over)
+		}
+	}
+	if over, err := NewMoney(8000, "HUF").Over(ctx, Money{}); err != nil || over {
+		t.Errorf("zero ceiling: got %t (%+v)", over, err)
+	}
+	if _, err := NewMoney(8000, "HUF").Over(context.Background(), ceiling); err == nil {
+		t.Error("wanted error without converter")
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	b, err := json.Marshal(struct{ A, B Money }{A: NewMoney(12.5, "EUR")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"A":{"amount":12.50,"currency":"EUR"},"B":null}`; got != want {
+		t.Errorf("got %s, wanted %s", got, want)
+	}
+	if b, err = json.Marshal(Fare{Price: NewMoney(9.99, "EUR")}); err != nil {
+		t.Fatal(err)
+	} else if s := string(b); strings.Contains(s, "returnPrice") || strings.Contains(s, "memberPrice") || strings.Contains(s, "originalPrice") {
+		t.Errorf("fare: got %s, wanted no zero prices", s)
+	}
+	var v struct{ A, B, C Money }
+	if err := json.Unmarshal([]byte(`{"A":{"amount":12.5,"currency":"EUR"},"B":null,"C":3.25}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != NewMoney(12.5, "EUR") || v.B != (Money{}) || v.C != NewMoney(3.25, "") {
+		t.Errorf("got %+v", v)
+	}
+}
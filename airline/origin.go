@@ -0,0 +1,93 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Origin is a departure airport, with the cost and time of the ground transfer to it.
+type Origin struct {
+	Code string `json:"code"`
+	// Cost is in the currency of the fares, so it has no Currency.
+	Cost   Money         `json:"cost,omitzero"`
+	Travel time.Duration `json:"travel,omitempty"`
+}
+
+func (o Origin) String() string {
+	if o.Cost.IsZero() && o.Travel == 0 {
+		return o.Code
+	}
+	return fmt.Sprintf("%s:%s:%s", o.Code, o.Cost.Amount(), o.Travel)
+}
+
+// Origins is a list of origins.
+type Origins []Origin
+
+// ParseOrigins parses a comma separated list of CODE[:COST[:TRAVEL]],
+// such as "BUD,VIE:30:2h30m,BTS:15:2h".
+func ParseOrigins(s string) (Origins, error) {
+	var origins Origins
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		code, rest, _ := strings.Cut(part, ":")
+		o := Origin{Code: strings.ToUpper(strings.TrimSpace(code))}
+		if rest != "" {
+			cost, travel, _ := strings.Cut(rest, ":")
+			var err error
+			if cost != "" {
+				if o.Cost, err = ParseMoney(cost, ""); err != nil {
+					return origins, fmt.Errorf("parse cost of %q: %w", part, err)
+				}
+			}
+			if travel != "" {
+				if o.Travel, err = time.ParseDuration(travel); err != nil {
+					return origins, fmt.Errorf("parse travel time of %q: %w", part, err)
+				}
+			}
+		}
+		origins = append(origins, o)
+	}
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("no origin in %q", s)
+	}
+	return origins, nil
+}
+
+// Get returns the Origin with the given code, or an Origin without transfer costs.
+func (oo Origins) Get(code string) Origin {
+	for _, o := range oo {
+		if o.Code == code {
+			return o
+		}
+	}
+	return Origin{Code: code}
+}
+
+// Codes returns the airport codes.
+func (oo Origins) Codes() []string {
+	codes := make([]string, len(oo))
+	for i, o := range oo {
+		codes[i] = o.Code
+	}
+	return codes
+}
+
+// Effective returns the price of the fare including the ground transfer to its origin.
+func (oo Origins) Effective(f Fare) Money {
+	eff, _ := f.Price.Add(oo.Get(f.Origin).CostIn(f.Price.Currency))
+	return eff
+}
+
+// CostIn returns the Cost in the currency.
+func (o Origin) CostIn(currency string) Money {
+	c := o.Cost
+	c.Currency = currency
+	return c
+}
@@ -0,0 +1,32 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseOrigins(t *testing.T) {
+	origins, err := ParseOrigins("BUD, vie:30:2h30m,BTS:15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Origins{{Code: "BUD"}, {Code: "VIE", Cost: NewMoney(30, ""), Travel: 150 * time.Minute}, {Code: "BTS", Cost: NewMoney(15, "")}}
+	if len(origins) != len(want) {
+		t.Fatalf("got %v, wanted %v", origins, want)
+	}
+	for i, o := range origins {
+		if o != want[i] {
+			t.Errorf("%d. got %v, wanted %v", i, o, want[i])
+		}
+	}
+	if got := origins.Effective(Fare{Origin: "VIE", Price: NewMoney(20, "EUR")}); got != NewMoney(50, "EUR") {
+		t.Errorf("effective: got %v, wanted 50 EUR", got)
+	}
+	if _, err := ParseOrigins("VIE:x"); err == nil {
+		t.Error("wanted error for bad cost")
+	}
+}
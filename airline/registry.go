@@ -0,0 +1,131 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Constructor creates a source.
+type Constructor func(ctx context.Context) (Airline, error)
+
+// ErrUnknownSource is returned for a source name which is not registered.
+var ErrUnknownSource = errors.New("unknown source")
+
+// Registry of the sources.
+//
+// The sources are created only when first used, and only once:
+// a failed initialization is remembered, and reported on each use.
+type Registry struct {
+	entries map[string]*registryEntry
+	mu      sync.Mutex
+}
+
+type registryEntry struct {
+	airline Airline
+	err     error
+	new     Constructor
+	once    sync.Once
+}
+
+// DefaultRegistry is where the sources register themselves, in their init.
+var DefaultRegistry = new(Registry)
+
+// Register the source constructor in the DefaultRegistry.
+func Register(name string, c Constructor) { DefaultRegistry.Register(name, c) }
+
+// Register the source constructor under name.
+//
+// Panics if the name is already registered.
+func (r *Registry) Register(name string, c Constructor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.entries == nil {
+		r.entries = make(map[string]*registryEntry)
+	}
+	if _, ok := r.entries[name]; ok {
+		panic(fmt.Sprintf("source %q registered twice", name))
+	}
+	r.entries[name] = &registryEntry{new: c}
+}
+
+// Names returns the registered source names, sorted.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.entries))
+	for nm := range r.entries {
+		names = append(names, nm)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Get returns the named source, initializing it at the first call.
+func (r *Registry) Get(ctx context.Context, name string) (Airline, error) {
+	r.mu.Lock()
+	e, ok := r.entries[name]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%q: %w (known: %s)", name, ErrUnknownSource, strings.Join(r.Names(), ", "))
+	}
+	e.once.Do(func() {
+		if e.airline, e.err = e.new(ctx); e.err != nil {
+			e.err = fmt.Errorf("init %s: %w", name, e.err)
+		}
+	})
+	return e.airline, e.err
+}
+
+// Select returns the names of the sources to use: the included ones
+// (all if include is empty), except the excluded ones.
+func (r *Registry) Select(include, exclude []string) ([]string, error) {
+	all := r.Names()
+	for _, nm := range append(append([]string(nil), include...), exclude...) {
+		if !slices.Contains(all, nm) {
+			return nil, fmt.Errorf("%q: %w (known: %s)", nm, ErrUnknownSource, strings.Join(all, ", "))
+		}
+	}
+	names := all
+	if len(include) != 0 {
+		names = slices.Clone(include)
+		slices.Sort(names)
+		names = slices.Compact(names)
+	}
+	return slices.DeleteFunc(names, func(nm string) bool { return slices.Contains(exclude, nm) }), nil
+}
+
+// Open the named sources, concurrently.
+//
+// The sources failing to initialize are left out of the returned map,
+// and their errors are joined into the returned error.
+func (r *Registry) Open(ctx context.Context, names []string) (map[string]Airline, error) {
+	airlines := make(map[string]Airline, len(names))
+	errs := make([]error, len(names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, nm := range names {
+		i, nm := i, nm
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			A, err := r.Get(ctx, nm)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			mu.Lock()
+			airlines[nm] = A
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return airlines, errors.Join(errs...)
+}
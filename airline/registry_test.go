@@ -0,0 +1,63 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+type nopAirline struct{}
+
+func (nopAirline) Destinations(ctx context.Context, origin string) ([]string, error) { return nil, nil }
+func (nopAirline) Fares(ctx context.Context, origin, destination string, departure time.Time, currency string) ([]Fare, error) {
+	return nil, nil
+}
+
+func TestRegistry(t *testing.T) {
+	var r Registry
+	var inits int
+	r.Register("a", func(context.Context) (Airline, error) { inits++; return nopAirline{}, nil })
+	r.Register("b", func(context.Context) (Airline, error) { return nil, errors.New("no cookies") })
+	r.Register("c", func(context.Context) (Airline, error) { return nopAirline{}, nil })
+
+	for _, tc := range []struct {
+		include, exclude, want []string
+	}{
+		{want: []string{"a", "b", "c"}},
+		{include: []string{"c", "a"}, want: []string{"a", "c"}},
+		{exclude: []string{"b"}, want: []string{"a", "c"}},
+		{include: []string{"a", "b"}, exclude: []string{"a"}, want: []string{"b"}},
+	} {
+		got, err := r.Select(tc.include, tc.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%q-%q: got %q, wanted %q", tc.include, tc.exclude, got, tc.want)
+		}
+	}
+	if _, err := r.Select([]string{"x"}, nil); !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("got %v, wanted ErrUnknownSource", err)
+	}
+
+	if inits != 0 {
+		t.Errorf("initialized before use")
+	}
+	ctx := context.Background()
+	airlines, err := r.Open(ctx, []string{"a", "b", "c"})
+	if err == nil {
+		t.Error("wanted the error of b")
+	}
+	if len(airlines) != 2 || airlines["a"] == nil || airlines["c"] == nil {
+		t.Errorf("got %v, wanted a and c", airlines)
+	}
+	if _, err = r.Get(ctx, "a"); err != nil || inits != 1 {
+		t.Errorf("got %v, %d inits, wanted one", err, inits)
+	}
+}
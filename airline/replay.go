@@ -0,0 +1,191 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Fixture is a recorded request/response pair.
+type Fixture struct {
+	Header      http.Header `json:"header,omitempty"`
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"requestBody,omitempty"`
+	Body        string      `json:"body"`
+	Status      int         `json:"status"`
+}
+
+func (f Fixture) key() string { return fixtureKey(f.Method, f.URL, f.RequestBody) }
+
+func fixtureKey(method, URL, body string) string {
+	return method + " " + URL + "\n" + body
+}
+
+// ErrNoFixture is returned in replay mode for requests without recorded response.
+var ErrNoFixture = fmt.Errorf("no fixture")
+
+// readBody reads and replaces the request body.
+func readBody(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return string(b), err
+}
+
+// NewRecordTransport returns a http.RoundTripper which does the requests with rt,
+// and writes each request/response pair as a Fixture into dir.
+func NewRecordTransport(dir string, rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return recordTransport{dir: dir, rt: rt}
+}
+
+type recordTransport struct {
+	rt  http.RoundTripper
+	dir string
+}
+
+func (t recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return resp, err
+	}
+	f := Fixture{
+		Method: req.Method, URL: req.URL.String(), RequestBody: reqBody,
+		Status: resp.StatusCode, Header: resp.Header, Body: string(b),
+	}
+	if err := writeFixture(t.dir, f); err != nil {
+		CtxLogger(req.Context()).Warn("record", "url", f.URL, "error", err)
+	}
+	return resp, nil
+}
+
+func writeFixture(dir string, f Fixture) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	hsh := sha256.Sum256([]byte(f.key()))
+	fn := filepath.Join(dir, strings.ToLower(f.Method)+"-"+req2name(f.URL)+"-"+hex.EncodeToString(hsh[:4])+".json")
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, b, 0640)
+}
+
+func req2name(URL string) string {
+	if _, rest, ok := strings.Cut(URL, "://"); ok {
+		URL = rest
+	}
+	URL, _, _ = strings.Cut(URL, "?")
+	name := strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '.' {
+			return r
+		}
+		return '_'
+	}, URL)
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// NewReplayTransport returns a http.RoundTripper which answers the requests
+// from the Fixtures recorded in the dirs, without any network access.
+//
+// The fixtures are read at the first request.
+func NewReplayTransport(dirs ...string) http.RoundTripper {
+	return &replayTransport{dirs: dirs}
+}
+
+type replayTransport struct {
+	err      error
+	fixtures map[string]Fixture
+	dirs     []string
+	once     sync.Once
+}
+
+func (t *replayTransport) load() {
+	t.fixtures = make(map[string]Fixture)
+	for _, dir := range t.dirs {
+		fns, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			t.err = err
+			return
+		}
+		for _, fn := range fns {
+			b, err := os.ReadFile(fn)
+			if err != nil {
+				t.err = err
+				return
+			}
+			var f Fixture
+			if err = json.Unmarshal(b, &f); err != nil {
+				t.err = fmt.Errorf("parse %q: %w", fn, err)
+				return
+			}
+			t.fixtures[f.key()] = f
+		}
+	}
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.once.Do(t.load)
+	if t.err != nil {
+		return nil, t.err
+	}
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := t.fixtures[fixtureKey(req.Method, req.URL.String(), reqBody)]
+	if !ok {
+		return nil, fmt.Errorf("%s %s [%s]: %w", req.Method, req.URL, reqBody, ErrNoFixture)
+	}
+	status := f.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := f.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}, nil
+}
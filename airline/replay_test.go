@@ -0,0 +1,67 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, r.Method+" "+r.URL.RequestURI()+" "+string(b))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	ctx := context.Background()
+	t.Setenv("FLY_RECORD", dir)
+	rec := NewClient(nil, false)
+	if _, _, err := rec.Get(ctx, srv.URL+"/a?b=c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := rec.Post(ctx, srv.URL+"/p", strings.NewReader("body")); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	t.Setenv("FLY_RECORD", "")
+	t.Setenv("FLY_REPLAY", dir)
+	rep := NewClient(nil, true)
+	for _, tc := range []struct{ method, path, body, want string }{
+		{"GET", "/a?b=c", "", "GET /a?b=c "},
+		{"POST", "/p", "body", "POST /p body"},
+	} {
+		var sr *io.SectionReader
+		var resp *http.Response
+		var err error
+		if tc.method == "GET" {
+			sr, resp, err = rep.Get(ctx, srv.URL+tc.path)
+		} else {
+			sr, resp, err = rep.Post(ctx, srv.URL+tc.path, strings.NewReader(tc.body))
+		}
+		if err != nil {
+			t.Fatalf("%s %s: %+v", tc.method, tc.path, err)
+		}
+		b, _ := io.ReadAll(sr)
+		if got := string(b); got != tc.want {
+			t.Errorf("%s %s: got %q, wanted %q", tc.method, tc.path, got, tc.want)
+		}
+		if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+			t.Errorf("%s %s: got Content-Type %q", tc.method, tc.path, got)
+		}
+	}
+
+	if _, _, err := rep.Post(ctx, srv.URL+"/p", strings.NewReader("other")); !errors.Is(err, ErrNoFixture) {
+		t.Errorf("got %+v, wanted ErrNoFixture", err)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// RoundTrip is an outbound and an inbound fare, with the total price.
+type RoundTrip struct {
+	Outbound Fare  `json:"outbound"`
+	Inbound  Fare  `json:"inbound"`
+	Price    Money `json:"price"`
+}
+
+// Stay returns the number of days between the outbound and the inbound day.
+func (rt RoundTrip) Stay() int { return daysBetween(rt.Outbound.Day, rt.Inbound.Day) }
+
+// RoundTripRequest describes a round trip search.
+//
+// If Return is zero, then any return day between Outbound+MinStay and Outbound+MaxStay is accepted.
+type RoundTripRequest struct {
+	Outbound, Return    time.Time
+	Origin, Destination string
+	Currency            string
+	MinStay, MaxStay    int
+}
+
+// ReturnDays returns the acceptable return days.
+func (req RoundTripRequest) ReturnDays() []time.Time {
+	if !req.Return.IsZero() {
+		return []time.Time{req.Return}
+	}
+	minStay, maxStay := req.MinStay, req.MaxStay
+	if maxStay < minStay {
+		maxStay = minStay
+	}
+	days := make([]time.Time, 0, maxStay-minStay+1)
+	for i := minStay; i <= maxStay; i++ {
+		days = append(days, req.Outbound.AddDate(0, 0, i))
+	}
+	return days
+}
+
+// AirlineRoundTrip is implemented by the sources which can price round trips themselves.
+type AirlineRoundTrip interface {
+	Airline
+	RoundTrips(ctx context.Context, req RoundTripRequest) ([]RoundTrip, error)
+}
+
+// RoundTrips searches the outbound and inbound fares of all the airlines,
+// and pairs them into round trips, cheapest first.
+//
+// If req.Destination is empty, all destinations are searched.
+func RoundTrips(ctx context.Context, airlines map[string]Airline, req RoundTripRequest) ([]RoundTrip, error) {
+	returnDays := req.ReturnDays()
+	if len(returnDays) == 0 {
+		return nil, fmt.Errorf("no return day")
+	}
+
+	var mu sync.Mutex
+	var outbound, inbound []Fare
+	var trips []RoundTrip
+	var errs []error
+	var wg sync.WaitGroup
+	for name, A := range airlines {
+		name, A := name, A
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rt, ok := A.(AirlineRoundTrip); ok {
+				local, err := rt.RoundTrips(ctx, req)
+				mu.Lock()
+				trips = append(trips, local...)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				}
+				mu.Unlock()
+				return
+			}
+			out, in, err := roundTripFares(ctx, A, req, returnDays)
+			mu.Lock()
+			outbound = append(outbound, out...)
+			inbound = append(inbound, in...)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	trips = append(trips, PairFares(outbound, inbound, req)...)
+	slices.SortStableFunc(trips, CmpRoundTrip)
+	return trips, errors.Join(errs...)
+}
+
+// roundTripFares returns the outbound fares, and the inbound fares for the destinations found.
+func roundTripFares(ctx context.Context, A Airline, req RoundTripRequest, returnDays []time.Time) ([]Fare, []Fare, error) {
+	var outbound []Fare
+	var err error
+	if req.Destination == "" {
+		outbound, err = WithAllFares(A).AllFares(ctx, req.Origin, req.Outbound, req.Currency)
+	} else {
+		outbound, err = A.Fares(ctx, req.Origin, req.Destination, req.Outbound, req.Currency)
+	}
+	if len(outbound) == 0 {
+		return nil, nil, err
+	}
+	outDay := req.Outbound.Format("2006-01-02")
+	dests := make(map[string]struct{})
+	for _, f := range outbound {
+		if f.Day == outDay {
+			dests[f.Destination] = struct{}{}
+		}
+	}
+
+	var grp errgroup.Group
+	grp.SetLimit(8)
+	var mu sync.Mutex
+	var inbound []Fare
+	for dest := range dests {
+		for _, day := range returnDays {
+			dest, day := dest, day
+			grp.Go(func() error {
+				local, err := A.Fares(ctx, dest, req.Origin, day, req.Currency)
+				mu.Lock()
+				inbound = append(inbound, local...)
+				mu.Unlock()
+				return err
+			})
+		}
+	}
+	return outbound, inbound, errors.Join(err, grp.Wait())
+}
+
+// PairFares pairs the outbound fares with the inbound fares,
+// returning the possible round trips.
+//
+// An inbound fare is paired with an outbound fare if it goes back from the outbound's destination
+// to its origin, in the same currency, on an acceptable return day, after the outbound arrived.
+func PairFares(outbound, inbound []Fare, req RoundTripRequest) []RoundTrip {
+	outDay := req.Outbound.Format("2006-01-02")
+	returnDays := make(map[string]struct{})
+	for _, d := range req.ReturnDays() {
+		returnDays[d.Format("2006-01-02")] = struct{}{}
+	}
+	slices.SortFunc(inbound, cmpFareKey)
+	inbound = slices.CompactFunc(inbound, func(a, b Fare) bool { return cmpFareKey(a, b) == 0 })
+
+	var trips []RoundTrip
+	for _, o := range outbound {
+		if o.Day != outDay {
+			continue
+		}
+		for _, i := range inbound {
+			if i.Origin != o.Destination || i.Destination != o.Origin || i.Price.Currency != o.Price.Currency {
+				continue
+			}
+			if _, ok := returnDays[i.Day]; !ok {
+				continue
+			}
+			if !o.Arrival.IsZero() && !i.Departure.IsZero() && !i.Departure.After(o.Arrival) {
+				continue
+			}
+			inPrice := i.Price
+			if !i.ReturnPrice.IsZero() && i.Source == o.Source {
+				inPrice = i.ReturnPrice
+			}
+			price, err := o.Price.Add(inPrice)
+			if err != nil {
+				continue
+			}
+			trips = append(trips, RoundTrip{Outbound: o, Inbound: i, Price: price})
+		}
+	}
+	return trips
+}
+
+// CmpRoundTrip orders the round trips by price, then by outbound and inbound departure.
+func CmpRoundTrip(a, b RoundTrip) int {
+	return cmp.Or(
+		a.Price.Cmp(b.Price),
+		a.Outbound.Departure.Compare(b.Outbound.Departure),
+		a.Inbound.Departure.Compare(b.Inbound.Departure),
+	)
+}
+
+func cmpFareKey(a, b Fare) int {
+	return cmp.Or(
+		cmp.Compare(a.Source, b.Source),
+		cmp.Compare(a.Origin, b.Origin),
+		cmp.Compare(a.Destination, b.Destination),
+		cmp.Compare(a.Day, b.Day),
+		a.Departure.Compare(b.Departure),
+		a.Price.Cmp(b.Price),
+	)
+}
+
+func daysBetween(a, b string) int {
+	ta, errA := time.Parse("2006-01-02", a)
+	tb, errB := time.Parse("2006-01-02", b)
+	if errA != nil || errB != nil {
+		return 0
+	}
+	return int(tb.Sub(ta).Hours()+12) / 24
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPairFares(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 10, d, 0, 0, 0, 0, time.UTC) }
+	fare := func(src, orig, dest string, d int, price, returnPrice float64) Fare {
+		return Fare{
+			Source: src, Origin: orig, Destination: dest,
+			Day: day(d).Format("2006-01-02"), Departure: day(d).Add(10 * time.Hour),
+			Arrival: day(d).Add(12 * time.Hour),
+			Price:   NewMoney(price, "EUR"), ReturnPrice: NewMoney(returnPrice, "EUR"),
+		}
+	}
+	outbound := []Fare{
+		fare("ryanair", "BUD", "STN", 1, 20, 0),
+		fare("easyjet", "BUD", "LGW", 1, 30, 0),
+		fare("ryanair", "BUD", "STN", 2, 10, 0), // wrong day
+	}
+	inbound := []Fare{
+		fare("ryanair", "STN", "BUD", 4, 25, 0),
+		fare("wizzair", "STN", "BUD", 5, 15, 0),
+		fare("easyjet", "LGW", "BUD", 4, 40, 35),
+		fare("ryanair", "STN", "BUD", 9, 5, 0), // too long stay
+		fare("ryanair", "STN", "VIE", 4, 5, 0), // wrong destination
+	}
+	trips := PairFares(outbound, inbound, RoundTripRequest{Outbound: day(1), MinStay: 3, MaxStay: 5})
+	if len(trips) != 3 {
+		t.Fatalf("got %d trips, wanted 3: %+v", len(trips), trips)
+	}
+	for _, rt := range trips {
+		var want float64
+		switch rt.Inbound.Source {
+		case "ryanair":
+			want = 45
+		case "wizzair":
+			want = 35
+		case "easyjet":
+			want = 65
+		}
+		if rt.Price != NewMoney(want, "EUR") {
+			t.Errorf("%s: got %v, wanted %f", rt.Inbound.Source, rt.Price, want)
+		}
+		if stay := rt.Stay(); stay < 3 || stay > 5 {
+			t.Errorf("stay %d", stay)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SearchRequestVersion is the current version of SearchRequest.
+//
+// It is incremented when an option is added, so a source can reject
+// the requests with options it does not know about.
+const SearchRequestVersion = 1
+
+// SearchRequest describes a search.
+//
+// The zero value of each option means the default: one adult, economy, nonstop, one-way, no price ceiling.
+type SearchRequest struct {
+	// Departure is the window of the outbound flights.
+	Departure DateRange
+	// Return is the window of the inbound flights (from Destination to Origin), if not zero.
+	Return DateRange
+	Origin string
+	// Destination is optional: all the destinations are searched if empty.
+	Destination string
+	Currency    string
+	Cabin       Cabin
+	Passengers  Passengers
+	// MaxStops is the maximal number of stops, AnyStops for no limit.
+	MaxStops int
+	// MaxPrice is the price ceiling (in Currency), if not zero.
+	// The fares in other currencies are converted to it for the comparison.
+	MaxPrice Money
+	// Version of the request, SearchRequestVersion if zero.
+	Version int
+}
+
+// AnyStops is the MaxStops for not limiting the stops.
+const AnyStops = -1
+
+// Passengers of the search. The zero value means one adult.
+type Passengers struct {
+	Adults, Children, Infants int
+}
+
+// IsZero reports whether it is the default: one adult.
+func (p Passengers) IsZero() bool {
+	return p == Passengers{} || p == Passengers{Adults: 1}
+}
+
+// Cabin is the travel class.
+type Cabin string
+
+const (
+	Economy        = Cabin("")
+	PremiumEconomy = Cabin("premium")
+	Business       = Cabin("business")
+	First          = Cabin("first")
+)
+
+// ParseCabin parses the cabin name (economy, premium, business, first).
+func ParseCabin(s string) (Cabin, error) {
+	switch c := Cabin(strings.ToLower(s)); c {
+	case "economy":
+		return Economy, nil
+	case Economy, PremiumEconomy, Business, First:
+		return c, nil
+	}
+	return Economy, fmt.Errorf("unknown cabin %q (economy, premium, business or first)", s)
+}
+
+// Option is a set of the SearchRequest options, for declaring the supported ones.
+type Option uint
+
+const (
+	OptPassengers = Option(1 << iota)
+	OptCabin
+	OptStops
+	OptReturn
+	OptMaxPrice
+)
+
+var optionNames = []string{"passengers", "cabin", "stops", "return", "max price"}
+
+func (o Option) String() string {
+	var names []string
+	for i, nm := range optionNames {
+		if o&(1<<i) != 0 {
+			names = append(names, nm)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// ErrUnsupported is returned for a request with an option the source does not support.
+var ErrUnsupported = errors.New("unsupported search option")
+
+// ErrUnknownOrigin is returned by the sources which know all their airports, for an origin they do not serve.
+var ErrUnknownOrigin = errors.New("unknown origin")
+
+// Options returns the options set to a non-default value.
+func (req SearchRequest) Options() Option {
+	var o Option
+	if !req.Passengers.IsZero() {
+		o |= OptPassengers
+	}
+	if req.Cabin != Economy {
+		o |= OptCabin
+	}
+	if req.MaxStops != 0 {
+		o |= OptStops
+	}
+	if !req.Return.IsZero() {
+		o |= OptReturn
+	}
+	if !req.MaxPrice.IsZero() {
+		o |= OptMaxPrice
+	}
+	return o
+}
+
+// Check returns an error if the request has an unknown version,
+// or uses an option which is not in supported.
+func (req SearchRequest) Check(supported Option) error {
+	if req.Version > SearchRequestVersion {
+		return fmt.Errorf("search request version %d (known: %d): %w", req.Version, SearchRequestVersion, ErrUnsupported)
+	}
+	if req.Origin == "" || req.Departure.IsZero() {
+		return fmt.Errorf("search request needs origin and departure")
+	}
+	if o := req.Options() &^ supported; o != 0 {
+		return fmt.Errorf("%s: %w", o, ErrUnsupported)
+	}
+	return nil
+}
+
+// AirlineSearch is implemented by the sources which accept a SearchRequest.
+type AirlineSearch interface {
+	Airline
+	// Search returns the fares for the request,
+	// or an error wrapping ErrUnsupported if it cannot honor an option.
+	Search(ctx context.Context, req SearchRequest) ([]Fare, error)
+}
+
+// WithSearch returns A if it implements AirlineSearch,
+// or an adapter using the Fares (and AllFares) method of A.
+//
+// The adapter supports the stops (as the old methods return direct flights),
+// the return and the max price options, and rejects the others.
+func WithSearch(A Airline) AirlineSearch {
+	if x, ok := A.(AirlineSearch); ok {
+		return x
+	}
+	return withSearch{Airline: A}
+}
+
+type withSearch struct{ Airline }
+
+func (co withSearch) Search(ctx context.Context, req SearchRequest) ([]Fare, error) {
+	return SearchWith(ctx, co.Airline, req, OptFares)
+}
+
+// OptFares are the options SearchFares honors: the stops (as the Fares methods return direct flights),
+// the return and the max price.
+const OptFares = OptStops | OptReturn | OptMaxPrice
+
+// SearchWith checks the options of req against supported, and searches with SearchFares.
+//
+// It is the Search of the sources whose Fares (and AllFares) methods return the price of
+// one adult in economy: their supported options are OptFares.
+func SearchWith(ctx context.Context, A Airline, req SearchRequest, supported Option) ([]Fare, error) {
+	if err := req.Check(supported); err != nil {
+		return nil, err
+	}
+	return SearchFares(ctx, A, req)
+}
+
+// SearchFares searches with the Fares (and AllFares) methods of A:
+// the fares in the Departure window, the inbound fares in the Return window,
+// and drops the ones above MaxPrice.
+//
+// It does not check the options, that is the job of the caller.
+func SearchFares(ctx context.Context, A Airline, req SearchRequest) ([]Fare, error) {
+	var fares []Fare
+	var err error
+	if req.Destination == "" {
+		fares, err = AllFaresInRange(ctx, A, req.Origin, req.Departure, req.Currency)
+	} else {
+		fares, err = FaresInRange(ctx, A, req.Origin, req.Destination, req.Departure, req.Currency)
+	}
+	errs := []error{err}
+	if !req.Return.IsZero() {
+		destinations := []string{req.Destination}
+		if req.Destination == "" {
+			destinations = destinations[:0]
+			for _, f := range fares {
+				destinations = append(destinations, f.Destination)
+			}
+			slices.Sort(destinations)
+			destinations = slices.Compact(destinations)
+		}
+		for _, dest := range destinations {
+			inbound, err := FaresInRange(ctx, A, dest, req.Origin, req.Return, req.Currency)
+			fares = append(fares, inbound...)
+			errs = append(errs, err)
+		}
+	}
+	if !req.MaxPrice.IsZero() {
+		// the fares which cannot be converted are kept, with the error
+		var convErr error
+		fares = slices.DeleteFunc(fares, func(f Fare) bool {
+			over, err := f.Price.Over(ctx, req.MaxPrice)
+			if err != nil && convErr == nil {
+				convErr = err
+			}
+			return over
+		})
+		errs = append(errs, convErr)
+	}
+	return fares, errors.Join(errs...)
+}
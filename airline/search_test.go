@@ -0,0 +1,68 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// pricedAirline returns one fare a day, the price is the day of the month.
+type pricedAirline struct{ nopAirline }
+
+func (pricedAirline) Fares(ctx context.Context, origin, destination string, departure time.Time, currency string) ([]Fare, error) {
+	return []Fare{{
+		Origin: origin, Destination: destination,
+		Day: departure.Format("2006-01-02"), Price: NewMoney(float64(departure.Day()), currency),
+	}}, nil
+}
+
+func TestSearchRequest(t *testing.T) {
+	day := time.Date(2024, 10, 10, 0, 0, 0, 0, time.UTC)
+	req := SearchRequest{Origin: "BUD", Destination: "STN", Departure: Flex(day, 2), Currency: "EUR"}
+	if o := req.Options(); o != 0 {
+		t.Errorf("default request has options %s", o)
+	}
+	req.Passengers.Adults = 1
+	if err := req.Check(0); err != nil {
+		t.Errorf("one adult: %+v", err)
+	}
+
+	ctx := context.Background()
+	A := WithSearch(pricedAirline{})
+	for _, bad := range []SearchRequest{
+		{Origin: "BUD", Departure: req.Departure, Passengers: Passengers{Adults: 2}},
+		{Origin: "BUD", Departure: req.Departure, Cabin: Business},
+		{Origin: "BUD", Departure: req.Departure, Version: SearchRequestVersion + 1},
+	} {
+		if _, err := A.Search(ctx, bad); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%+v: got %v, wanted ErrUnsupported", bad, err)
+		}
+	}
+
+	req.Return = Day(day.AddDate(0, 0, 7))
+	req.MaxPrice = NewMoney(16.5, "EUR")
+	req.MaxStops = AnyStops
+	fares, err := A.Search(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range fares {
+		got = append(got, f.Origin+"-"+f.Destination+" "+f.Day)
+	}
+	// 8..12 outbound, 17 inbound is above the max price
+	if len(fares) != 5 || fares[0].Day != "2024-10-08" || fares[4].Day != "2024-10-12" {
+		t.Errorf("got %q", got)
+	}
+	req.MaxPrice = Money{}
+	if fares, err = A.Search(ctx, req); err != nil {
+		t.Fatal(err)
+	} else if f := fares[len(fares)-1]; f.Origin != "STN" || f.Destination != "BUD" || f.Day != "2024-10-17" {
+		t.Errorf("got %+v, wanted the inbound fare", f)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of one search of a source, from one origin.
+type Result struct {
+	Source, Origin string
+	Fares          []Fare
+	// Err is prefixed with the Source.
+	Err error
+	// Dur is the duration of the search.
+	Dur time.Duration
+	// Partial results have the fares of one destination of a search which asks the destinations
+	// one by one, as soon as they arrive; the final (not Partial) Result of the search has all its fares again,
+	// filtered by the request.
+	Partial bool
+}
+
+// Stream searches with all the airlines from all the origins concurrently,
+// and sends the Result of each search on the returned channel as soon as it completes,
+// so the fares of the fast sources can be used before the slowest one finishes.
+// The searches which ask each destination send a Partial result for each of them, too.
+//
+// The channel is closed when all the searches are done, the caller must read it till then.
+// The Partial results are dropped after the context is canceled.
+func Stream(ctx context.Context, airlines map[string]Airline, origins []string, req SearchRequest) <-chan Result {
+	ch := make(chan Result, len(airlines)*len(origins))
+	var wg sync.WaitGroup
+	for name, A := range airlines {
+		for _, origin := range origins {
+			name, A, origin := name, A, origin
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := req
+				req.Origin = origin
+				start := time.Now()
+				pctx := withPartial(ctx, func(fares []Fare) {
+					select {
+					case ch <- Result{Source: name, Origin: origin, Fares: fares, Dur: time.Since(start), Partial: true}:
+					case <-ctx.Done():
+					}
+				})
+				fares, err := WithSearch(A).Search(pctx, req)
+				res := Result{Source: name, Origin: origin, Fares: fares, Dur: time.Since(start)}
+				if err != nil {
+					res.Err = fmt.Errorf("%s: %w", name, err)
+				}
+				ch <- res
+			}()
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(ch)
+	}()
+	return ch
+}
+
+type partialKey struct{}
+
+// withPartial returns a context which makes eachDestination report the fares of each destination to f.
+func withPartial(ctx context.Context, f func([]Fare)) context.Context {
+	return context.WithValue(ctx, partialKey{}, f)
+}
+
+func ctxPartial(ctx context.Context) func([]Fare) {
+	f, _ := ctx.Value(partialKey{}).(func([]Fare))
+	return f
+}
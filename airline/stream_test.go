@@ -0,0 +1,81 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package airline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gatedAirline fails, but only after the gate is closed.
+type gatedAirline struct {
+	nopAirline
+	gate chan struct{}
+}
+
+func (ga gatedAirline) Fares(ctx context.Context, origin, destination string, departure time.Time, currency string) ([]Fare, error) {
+	<-ga.gate
+	return nil, errors.New("slow and broken")
+}
+
+func TestStream(t *testing.T) {
+	gate := make(chan struct{})
+	airlines := map[string]Airline{"priced": pricedAirline{}, "gated": gatedAirline{gate: gate}}
+	day := time.Date(2024, 10, 10, 0, 0, 0, 0, time.UTC)
+	ch := Stream(context.Background(), airlines, []string{"BUD", "VIE"},
+		SearchRequest{Destination: "STN", Departure: Flex(day, 1), Currency: "EUR"})
+
+	// the fast source arrives while the slow one is still searching
+	for i := 0; i < 2; i++ {
+		res := <-ch
+		if res.Source != "priced" || res.Err != nil || len(res.Fares) != 3 {
+			t.Errorf("%d. got %+v, wanted 3 fares of priced", i, res)
+		}
+	}
+	close(gate)
+	var n int
+	for res := range ch {
+		n++
+		if res.Source != "gated" || res.Err == nil || !strings.HasPrefix(res.Err.Error(), "gated: slow and broken") {
+			t.Errorf("got %+v, wanted the error of gated", res)
+		}
+	}
+	if n != 2 {
+		t.Errorf("got %d results of gated, wanted 2", n)
+	}
+}
+
+// twoDestinations has two destinations, without its own AllFares.
+type twoDestinations struct{ pricedAirline }
+
+func (twoDestinations) Destinations(ctx context.Context, origin string) ([]string, error) {
+	return []string{"STN", "LIS"}, nil
+}
+
+func TestStreamPartial(t *testing.T) {
+	day := time.Date(2024, 10, 10, 0, 0, 0, 0, time.UTC)
+	var partials, final int
+	for res := range Stream(context.Background(), map[string]Airline{"two": twoDestinations{}}, []string{"BUD"},
+		SearchRequest{Departure: Flex(day, 1), Currency: "EUR"},
+	) {
+		if res.Partial {
+			partials++
+			if len(res.Fares) != 1 {
+				t.Errorf("partial: got %+v, wanted the fare of one destination", res)
+			}
+			continue
+		}
+		final++
+		if len(res.Fares) != 6 || res.Err != nil {
+			t.Errorf("final: got %+v, wanted 6 fares", res)
+		}
+	}
+	if partials != 6 || final != 1 {
+		t.Errorf("got %d partial and %d final results, wanted 6 and 1", partials, final)
+	}
+}
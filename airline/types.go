@@ -5,6 +5,7 @@
 package airline
 
 import (
+	"slices"
 	"time"
 )
 
@@ -43,6 +44,72 @@ type Fare struct {
 	Origin      string    `json:"origin"`
 	Destination string    `json:"destination"`
 	Day         string    `json:"day"`
-	Currency    string    `json:"currency"`
-	Price       float64   `json:"price"`
+	Price       Money     `json:"price"`
+	// ReturnPrice is the price of this flight when booked as the return leg of a round trip,
+	// if the source reports it.
+	ReturnPrice Money `json:"returnPrice,omitzero"`
+	// MemberPrice is the price for the members of the discount club of the airline, if any.
+	MemberPrice Money `json:"memberPrice,omitzero"`
+	// OriginalPrice is the price before the discount of Price (the "was" price), if the source reports it.
+	OriginalPrice Money `json:"originalPrice,omitzero"`
+	// RegularPrice is the price for everyone, if Price is the MemberPrice.
+	RegularPrice Money `json:"regularPrice,omitzero"`
+	// Bundle is the name of the fare bundle (fare class) of Price, if known.
+	Bundle string `json:"bundle,omitempty"`
+	// FlightNumbers of the segments, if known.
+	FlightNumbers []string `json:"flightNumbers,omitempty"`
+	// Segments are the flights of the fare, empty if unknown.
+	Segments []Segment `json:"segments,omitempty"`
+	// Duration is the time from the departure of the first segment to the arrival of the last one.
+	Duration time.Duration `json:"duration,omitempty"`
+	// Stops is the number of stops.
+	Stops int `json:"stops,omitempty"`
+	// Sources are all the sources which reported this flight, set by MergeFares.
+	Sources []string `json:"sources,omitempty"`
+}
+
+// Segment is one flight of a Fare.
+type Segment struct {
+	Departure    time.Time     `json:"departureDate"`
+	Arrival      time.Time     `json:"arrivalDate"`
+	Airline      string        `json:"airline,omitempty"`
+	FlightNumber string        `json:"flightNumber,omitempty"`
+	Origin       string        `json:"origin"`
+	Destination  string        `json:"destination"`
+	Duration     time.Duration `json:"duration,omitempty"`
+}
+
+// Direct returns the fare as a direct flight: with one segment filled from the fare itself,
+// and the duration if both the departure and the arrival are known.
+func (f Fare) Direct(flightNumber string) Fare {
+	seg := Segment{
+		Departure: f.Departure, Arrival: f.Arrival,
+		Airline: f.Airline, FlightNumber: flightNumber,
+		Origin: f.Origin, Destination: f.Destination,
+	}
+	if !f.Departure.IsZero() && !f.Arrival.IsZero() {
+		seg.Duration = f.Arrival.Sub(f.Departure)
+	}
+	f.Segments, f.Duration, f.Stops = []Segment{seg}, seg.Duration, 0
+	if flightNumber != "" {
+		f.FlightNumbers = []string{flightNumber}
+	}
+	return f
+}
+
+// Equal reports whether the two fares are the same.
+func (f Fare) Equal(g Fare) bool {
+	return f.Arrival.Equal(g.Arrival) && f.Departure.Equal(g.Departure) &&
+		f.Airline == g.Airline && f.Source == g.Source &&
+		f.Origin == g.Origin && f.Destination == g.Destination &&
+		f.Day == g.Day &&
+		f.Price == g.Price && f.ReturnPrice == g.ReturnPrice && f.MemberPrice == g.MemberPrice &&
+		f.OriginalPrice == g.OriginalPrice && f.RegularPrice == g.RegularPrice &&
+		f.Bundle == g.Bundle && f.Duration == g.Duration && f.Stops == g.Stops &&
+		slices.Equal(f.FlightNumbers, g.FlightNumbers) && slices.Equal(f.Sources, g.Sources) &&
+		slices.EqualFunc(f.Segments, g.Segments, func(a, b Segment) bool {
+			return a.Departure.Equal(b.Departure) && a.Arrival.Equal(b.Arrival) &&
+				a.Airline == b.Airline && a.FlightNumber == b.FlightNumber &&
+				a.Origin == b.Origin && a.Destination == b.Destination && a.Duration == b.Duration
+		})
 }
@@ -0,0 +1,104 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+func newCacheCmd() *ffcli.Command {
+	FS := flag.NewFlagSet("cache", flag.ContinueOnError)
+	flagDir := FS.String("dir", airline.CacheDir(), "HTTP cache directory")
+	// source returns the SOURCE argument, which must be a registered source
+	// or an existing cache (or the old, flat cache).
+	source := func(args []string) (string, error) {
+		switch len(args) {
+		case 0:
+			return "", nil
+		case 1:
+		default:
+			return "", fmt.Errorf("at most one source is allowed, got %q", args)
+		}
+		name := args[0]
+		if err := airline.CheckCacheName(name); err != nil {
+			return "", err
+		}
+		if name == airline.FlatCache || slices.Contains(airline.DefaultRegistry.Names(), name) {
+			return name, nil
+		}
+		if fi, err := os.Stat(filepath.Join(*flagDir, name)); err == nil && fi.IsDir() {
+			return name, nil
+		}
+		return "", fmt.Errorf("unknown source %q (known: %s)", name, strings.Join(airline.DefaultRegistry.Names(), ", "))
+	}
+
+	statsCmd := ffcli.Command{Name: "stats",
+		ShortUsage: "stats [SOURCE]",
+		ShortHelp:  "show the number, size and the stale ones of the cached responses",
+		Exec: func(ctx context.Context, args []string) error {
+			name, err := source(args)
+			if err != nil {
+				return err
+			}
+			stats, err := airline.CacheStats(*flagDir, name, time.Now())
+			tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(tw, "source\tentries\tstale\tbytes\t")
+			for _, st := range stats {
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t\n", st.Name, st.Entries, st.Stale, st.Size)
+			}
+			if flushErr := tw.Flush(); err == nil {
+				err = flushErr
+			}
+			return err
+		},
+	}
+
+	clearCmd := ffcli.Command{Name: "clear",
+		ShortUsage: "clear [SOURCE]",
+		ShortHelp:  "remove all the cached responses",
+		Exec: func(ctx context.Context, args []string) error {
+			name, err := source(args)
+			if err != nil {
+				return err
+			}
+			return airline.ClearCache(*flagDir, name)
+		},
+	}
+
+	pruneCmd := ffcli.Command{Name: "prune",
+		ShortUsage: "prune [SOURCE]",
+		ShortHelp:  "remove the stale cached responses",
+		Exec: func(ctx context.Context, args []string) error {
+			name, err := source(args)
+			if err != nil {
+				return err
+			}
+			n, err := airline.PruneCache(*flagDir, name, time.Now())
+			fmt.Printf("removed %d stale responses\n", n)
+			return err
+		},
+	}
+
+	return &ffcli.Command{Name: "cache", FlagSet: FS,
+		ShortUsage:  "cache [flags] stats|clear|prune [SOURCE]",
+		ShortHelp:   "inspect or clear the HTTP cache",
+		Subcommands: []*ffcli.Command{&statsCmd, &clearCmd, &pruneCmd},
+		Exec: func(ctx context.Context, args []string) error {
+			return flag.ErrHelp
+		},
+	}
+}
@@ -20,25 +20,32 @@ import (
 type EasyJet struct{ Client airline.HTTPClient }
 
 var _ airline.Airline = EasyJet{}
+var _ airline.Spanner = EasyJet{}
+var _ airline.AirlineSearch = EasyJet{}
 
 const baseURL = "https://www.easyjet.com/api/routepricing/v3"
 const routesURL = baseURL + "/Routes"
 
+// routes remembers the whole route list (of each client), which is needed for every Destinations call.
+var routes = airline.Memo[airline.ClientKey[string], []route]{TTL: time.Hour}
+
 func (ej EasyJet) getRoutes(ctx context.Context) ([]route, error) {
-	sr, _, err := ej.Client.Get(
-		airline.WithPrepare(ctx, func(r *http.Request) {
-			r.Header.Set("Accept", "application/json")
-		}),
-		routesURL)
-	if err != nil {
-		return nil, err
-	}
-	var routes []route
-	b, _ := io.ReadAll(sr)
-	if err = json.Unmarshal(b, &routes); err == nil && len(routes) == 0 {
-		airline.CtxLogger(ctx).Warn("getRoutes", "URL", routesURL, "response", string(b))
-	}
-	return routes, err
+	return routes.Do(ctx, airline.MemoKey(ej.Client, routesURL), func(ctx context.Context) ([]route, error) {
+		sr, _, err := ej.Client.Get(
+			airline.WithPrepare(ctx, func(r *http.Request) {
+				r.Header.Set("Accept", "application/json")
+			}),
+			routesURL)
+		if err != nil {
+			return nil, err
+		}
+		var routes []route
+		b, _ := io.ReadAll(sr)
+		if err = json.Unmarshal(b, &routes); err == nil && len(routes) == 0 {
+			airline.CtxLogger(ctx).Warn("getRoutes", "URL", routesURL, "response", string(b))
+		}
+		return routes, err
+	})
 }
 
 func (ej EasyJet) Destinations(ctx context.Context, origin string) ([]string, error) {
@@ -91,6 +98,19 @@ const (
 	airlineName = "EasyJet"
 )
 
+func init() {
+	airline.Register(sourceName, func(ctx context.Context) (airline.Airline, error) {
+		return EasyJet{Client: airline.NewClient(nil, true, airline.WithCachePolicy(cachePolicy))}, nil
+	})
+}
+
+// cachePolicy caches the routes for a day, the fares for half an hour.
+var cachePolicy = airline.CachePolicy{Name: sourceName, Rules: []airline.CacheRule{
+	{Match: routesURL, TTL: 24 * time.Hour},
+	{Match: "/flights-timetables", TTL: 24 * time.Hour},
+	{Match: "/searchfares/", TTL: 30 * time.Minute},
+}}
+
 const searchFaresURL = baseURL + "/searchfares/GetLowestDailyFares?departureAirport={{origin}}&arrivalAirport={{destination}}&currency={{currency}}"
 
 func (ej EasyJet) Fares(ctx context.Context, origin, destination string, departDate time.Time, currency string) ([]airline.Fare, error) {
@@ -130,11 +150,32 @@ func (ej EasyJet) Fares(ctx context.Context, origin, destination string, departD
 			Arrival: arrival, Departure: departure,
 			Day:    departure.Format("2006-01-02"),
 			Origin: f.Origin, Destination: f.Destination,
-			Price: f.Price, Currency: currency,
-		})
+			Price:       airline.NewMoney(f.Price, currency),
+			ReturnPrice: airline.NewMoney(f.ReturnPrice, currency),
+		}.Direct(flightNumber(f.FlightNumber)))
+	}
+	if err != nil {
+		return fares, err
 	}
+	return airline.ConvertFares(ctx, fares, currency)
+}
+
+// Search is airline.SearchWith the options of GetLowestDailyFares.
+func (ej EasyJet) Search(ctx context.Context, req airline.SearchRequest) ([]airline.Fare, error) {
+	return airline.SearchWith(ctx, ej, req, airline.OptFares)
+}
+
+// flightNumber returns the flight number with the IATA code of EasyJet.
+func flightNumber(number string) string {
+	if number == "" {
+		return ""
+	}
+	return "U2" + number
+}
 
-	return fares, err
+// Span returns a year from departure, as GetLowestDailyFares returns all the known fares regardless of the date.
+func (ej EasyJet) Span(departure time.Time) airline.DateRange {
+	return airline.DateRange{From: departure, To: departure.AddDate(1, 0, 0)}
 }
 
 /*
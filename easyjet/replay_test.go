@@ -0,0 +1,43 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package easyjet
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+func TestReplay(t *testing.T) {
+	t.Setenv("FLY_REPLAY", "testdata/replay")
+	ej := EasyJet{Client: airline.NewClient(nil, true)}
+	ctx := context.Background()
+
+	dests, err := ej.Destinations(ctx, "BUD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"LGW", "BSL"}; !slices.Equal(dests, want) {
+		t.Errorf("got %q, wanted %q", dests, want)
+	}
+
+	day := time.Date(2024, 10, 15, 0, 0, 0, 0, time.Local)
+	fares, err := airline.FaresInRange(ctx, ej, "BUD", "LGW", airline.Flex(day, 1), "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fares) != 2 {
+		t.Fatalf("got %d fares, wanted 2: %+v", len(fares), fares)
+	}
+	slices.SortFunc(fares, func(a, b airline.Fare) int { return a.Departure.Compare(b.Departure) })
+	if f := fares[1]; f.Day != "2024-10-15" || f.Price != airline.NewMoney(21.41, "EUR") || f.ReturnPrice != airline.NewMoney(28.55, "EUR") {
+		t.Errorf("got %+v", f)
+	} else if !slices.Equal(f.FlightNumbers, []string{"U28732"}) || f.Duration != 2*time.Hour+45*time.Minute || len(f.Segments) != 1 || f.Stops != 0 {
+		t.Errorf("got %+v, wanted flight U28732 of 2h45m", f)
+	}
+}
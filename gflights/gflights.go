@@ -23,6 +23,15 @@ var cities map[string]string
 
 const sourceName = "gflights"
 
+func init() {
+	airline.Register(sourceName, func(ctx context.Context) (airline.Airline, error) {
+		return New(ctx)
+	})
+}
+
+// City returns the name of the city of the airport, or the empty string if unknown.
+func City(code string) string { return cities[code] }
+
 func New(ctx context.Context) (GFlights, error) {
 	session, err := flights.New()
 	if err != nil {
@@ -35,7 +44,8 @@ type GFlights struct {
 	session *flights.Session
 }
 
-var _ airline.Airline = GFlights{}
+var _ airline.AirlineRoundTrip = GFlights{}
+var _ airline.AirlineSearch = GFlights{}
 
 func (G GFlights) Destinations(ctx context.Context, origin string) ([]string, error) {
 	dests := make([]string, 0, len(cities))
@@ -46,28 +56,163 @@ func (G GFlights) Destinations(ctx context.Context, origin string) ([]string, er
 }
 
 func (G GFlights) AllFares(ctx context.Context, origin string, departure time.Time, curr string) ([]airline.Fare, error) {
+	return withOptions{GFlights: G}.AllFares(ctx, origin, departure, curr)
+}
+
+func (G GFlights) Fares(ctx context.Context, origin, destination string, departure time.Time, curr string) ([]airline.Fare, error) {
+	return withOptions{GFlights: G}.Fares(ctx, origin, destination, departure, curr)
+}
+
+// Search honors all the options: the passengers, the cabin and the stops are passed to Google Flights,
+// and Price is for all the passengers.
+func (G GFlights) Search(ctx context.Context, req airline.SearchRequest) ([]airline.Fare, error) {
+	if err := req.Check(airline.OptPassengers | airline.OptCabin | airline.OptStops | airline.OptReturn | airline.OptMaxPrice); err != nil {
+		return nil, err
+	}
+	return airline.SearchFares(ctx, withOptions{GFlights: G, req: req}, req)
+}
+
+// withOptions searches with the passengers, cabin and stops of req.
+type withOptions struct {
+	GFlights
+	req airline.SearchRequest
+}
+
+func (w withOptions) AllFares(ctx context.Context, origin string, departure time.Time, curr string) ([]airline.Fare, error) {
 	destCities := make([]string, 0, len(cities))
 	for _, s := range cities {
 		destCities = append(destCities, s)
 	}
-	return G.fares(ctx, origin, destCities, departure, curr)
+	return w.fares(ctx, origin, destCities, departure, curr)
 }
 
-func (G GFlights) Fares(ctx context.Context, origin, destination string, departure time.Time, curr string) ([]airline.Fare, error) {
-	return G.fares(ctx, origin, []string{cities[destination]}, departure, curr)
+func (w withOptions) Fares(ctx context.Context, origin, destination string, departure time.Time, curr string) ([]airline.Fare, error) {
+	return w.fares(ctx, origin, []string{cities[destination]}, departure, curr)
 }
 
-func (G GFlights) fares(ctx context.Context, origin string, destCities []string, departure time.Time, curr string) ([]airline.Fare, error) {
+func (w withOptions) fares(ctx context.Context, origin string, destCities []string, departure time.Time, curr string) ([]airline.Fare, error) {
+	offers, CURR, err := w.offers(ctx, origin, destCities, departure, departure.AddDate(0, 0, 37), flights.OneWay, curr, w.options())
+	fares := make([]airline.Fare, 0, len(offers))
+	for _, o := range offers {
+		fares = append(fares, offerFare(o, CURR))
+	}
+	if err != nil {
+		return fares, err
+	}
+	return airline.ConvertFares(ctx, fares, curr)
+}
+
+// RoundTrips returns the round trip offers.
+//
+// Google Flights prices the round trip as a whole, but does not return the inbound flight,
+// so only the day of the Inbound is filled.
+func (G GFlights) RoundTrips(ctx context.Context, req airline.RoundTripRequest) ([]airline.RoundTrip, error) {
+	destCities := make([]string, 0, len(cities))
+	if req.Destination != "" {
+		destCities = append(destCities, cities[req.Destination])
+	} else {
+		for _, s := range cities {
+			destCities = append(destCities, s)
+		}
+	}
+	var trips []airline.RoundTrip
+	for _, returnDate := range req.ReturnDays() {
+		offers, CURR, err := G.offers(ctx, req.Origin, destCities, req.Outbound, returnDate, flights.RoundTrip, req.Currency, withOptions{}.options())
+		for _, o := range offers {
+			out := offerFare(o, CURR)
+			trips = append(trips, airline.RoundTrip{
+				Outbound: out,
+				Inbound: airline.Fare{
+					Airline: out.Airline, Source: sourceName,
+					Origin: out.Destination, Destination: out.Origin,
+					Day: o.ReturnDate.Format("2006-01-02"),
+				},
+				Price: out.Price,
+			})
+		}
+		if err != nil {
+			return trips, err
+		}
+	}
+	return trips, nil
+}
+
+func offerFare(o flights.FullOffer, CURR currency.Unit) airline.Fare {
+	f := airline.Fare{
+		Source:      sourceName,
+		Day:         o.StartDate.Format("2006-01-02"),
+		Arrival:     o.StartDate.Add(o.FlightDuration),
+		Departure:   o.StartDate,
+		Price:       airline.NewMoney(o.Price, CURR.String()),
+		Origin:      o.SrcAirportCode,
+		Destination: o.DstAirportCode,
+		Duration:    o.FlightDuration,
+	}
+	if len(o.Flight) == 0 {
+		return f
+	}
+	f.Airline = o.Flight[0].AirlineName
+	f.Stops = len(o.Flight) - 1
+	for _, fl := range o.Flight {
+		f.Segments = append(f.Segments, airline.Segment{
+			Departure: fl.DepTime, Arrival: fl.ArrTime,
+			Airline: fl.AirlineName, FlightNumber: fl.FlightNumber,
+			Origin: fl.DepAirportCode, Destination: fl.ArrAirportCode,
+			Duration: fl.Duration,
+		})
+		if fl.FlightNumber != "" {
+			f.FlightNumbers = append(f.FlightNumbers, fl.FlightNumber)
+		}
+	}
+	if last := o.Flight[len(o.Flight)-1].ArrTime; !last.IsZero() {
+		f.Arrival = last
+	}
+	return f
+}
+
+// options returns the passengers, stops and class of the request.
+func (w withOptions) options() flights.Options {
+	opts := flights.Options{
+		Travelers: flights.Travelers{
+			Adults:   max(1, w.req.Passengers.Adults),
+			Children: w.req.Passengers.Children, InfantOnLap: w.req.Passengers.Infants,
+		},
+		Stops: flights.Nonstop,
+		Class: flights.Economy,
+	}
+	switch w.req.MaxStops {
+	case 0:
+	case 1:
+		opts.Stops = flights.Stop1
+	case 2:
+		opts.Stops = flights.Stop2
+	default:
+		opts.Stops = flights.AnyStops
+	}
+	switch w.req.Cabin {
+	case airline.PremiumEconomy:
+		opts.Class = flights.PremiumEconomy
+	case airline.Business:
+		opts.Class = flights.Business
+	case airline.First:
+		opts.Class = flights.First
+	}
+	return opts
+}
+
+// offers returns the offers, with the Travelers, Stops and Class of opts.
+func (G GFlights) offers(ctx context.Context, origin string, destCities []string, departure, returnDate time.Time, tripType flights.TripType, curr string, opts flights.Options) ([]flights.FullOffer, currency.Unit, error) {
 	logger := airline.CtxLogger(ctx)
 	CURR, err := currency.ParseISO(curr)
 	if err != nil {
-		return nil, err
+		return nil, CURR, err
 	}
+	opts.Currency, opts.TripType, opts.Lang = CURR, tripType, language.English
 	originCity := cities[origin]
 	// logger.Info("collected", "cities", destCities)
 
 	var mu sync.Mutex
-	var fares []airline.Fare
+	var offers []flights.FullOffer
 	grp, grpCtx := errgroup.WithContext(ctx)
 	for i := 0; i < 8; i++ {
 		remainder := i
@@ -80,21 +225,14 @@ func (G GFlights) fares(ctx context.Context, origin string, destCities []string,
 		}
 		grp.Go(func() error {
 			start := time.Now()
-			offers, _, err := G.session.GetOffers(
+			local, _, err := G.session.GetOffers(
 				grpCtx,
 				flights.Args{
 					Date:       departure,
-					ReturnDate: departure.AddDate(0, 0, 37),
+					ReturnDate: returnDate,
 					SrcCities:  []string{originCity},
 					DstCities:  cities,
-					Options: flights.Options{
-						Travelers: flights.Travelers{Adults: 1},
-						Currency:  CURR,
-						Stops:     flights.Nonstop,
-						Class:     flights.Economy,
-						TripType:  flights.OneWay,
-						Lang:      language.English,
-					},
+					Options:    opts,
 				},
 			)
 			if err != nil {
@@ -105,23 +243,11 @@ func (G GFlights) fares(ctx context.Context, origin string, destCities []string,
 			}
 
 			mu.Lock()
-			defer mu.Unlock()
-			for _, o := range offers {
-				fares = append(fares, airline.Fare{
-					Airline:     o.Flight[0].AirlineName,
-					Source:      sourceName,
-					Day:         o.StartDate.Format("2006-01-02"),
-					Arrival:     o.StartDate.Add(o.FlightDuration),
-					Departure:   o.StartDate,
-					Price:       o.Price,
-					Currency:    CURR.String(),
-					Origin:      o.SrcAirportCode,
-					Destination: o.DstAirportCode,
-				})
-			}
+			offers = append(offers, local...)
+			mu.Unlock()
 			return nil
 		})
 	}
 	err = grp.Wait()
-	return fares, err
+	return offers, CURR, err
 }
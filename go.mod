@@ -1,6 +1,6 @@
 module github.com/tgulacsi/fly
 
-go 1.22.6
+go 1.24
 
 require (
 	github.com/PuerkitoBio/goquery v1.9.2
@@ -13,8 +13,10 @@ require (
 	github.com/remerge/chd v0.0.0-20231129170501-9dfedd7b8bbf
 	github.com/tgulacsi/go v0.27.5
 	github.com/tgulacsi/mnbarf v0.5.1
+	go.etcd.io/bbolt v1.3.11
 	golang.org/x/sync v0.6.0
 	golang.org/x/text v0.14.0
+	golang.org/x/time v0.6.0
 )
 
 require (
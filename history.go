@@ -0,0 +1,95 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+
+	"github.com/tgulacsi/fly/airline"
+	"github.com/tgulacsi/fly/history"
+)
+
+func defaultHistoryPath() string {
+	path, _ := history.DefaultPath()
+	return path
+}
+
+func newHistoryCmd() *ffcli.Command {
+	FS := flag.NewFlagSet("history", flag.ContinueOnError)
+	flagPath := FS.String("db", defaultHistoryPath(), "history database")
+	flagTemplate := FS.String("template", "{{.Day}} {{.Departure.Format \"15:04\"}}\t{{.Source}}\t{{.Observed.Format \"2006-01-02 15:04\"}}\t{{.DaysBefore}}d\t{{printf \"% 3.2f\" .Price}} {{.Price.Currency}}\t{{if .Change}}{{printf \"%+.2f\" .Change}}{{end}}\n",
+		"template for printing")
+	return &ffcli.Command{Name: "history", FlagSet: FS,
+		ShortUsage: "history [flags] ORIGIN-DESTINATION [DAY]",
+		ShortHelp:  "show how the price of a route changed across runs",
+		Exec: func(ctx context.Context, args []string) error {
+			db, err := history.Open(*flagPath)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			if len(args) == 0 {
+				routes, err := db.Routes()
+				for _, r := range routes {
+					fmt.Println(r)
+				}
+				return err
+			}
+			origin, destination, err := history.ParseRoute(args[0])
+			if err != nil {
+				return err
+			}
+			var day string
+			if len(args) > 1 {
+				t, err := parseDate(args[1])
+				if err != nil {
+					return err
+				}
+				day = t.Format("2006-01-02")
+			}
+			records, err := db.Route(origin, destination, day)
+			if err != nil {
+				return err
+			}
+			tmpl := template.Must(template.New("print").Parse(*flagTemplate))
+			bw := bufio.NewWriter(os.Stdout)
+			type flight struct {
+				Day, Source string
+				Departure   time.Time
+			}
+			last := make(map[flight]airline.Fare)
+			for _, r := range records {
+				var change float64
+				k := flight{Day: r.Day, Source: r.Source, Departure: r.Departure}
+				if prev, ok := last[k]; ok {
+					if d, err := r.Price.Sub(prev.Price); err == nil {
+						change = d.Float64()
+					}
+				}
+				last[k] = r.Fare
+				var daysBefore int
+				if t, err := time.Parse("2006-01-02", r.Day); err == nil {
+					daysBefore = int(t.Sub(r.Observed.Truncate(24*time.Hour)).Hours()) / 24
+				}
+				if err := tmpl.Execute(bw, struct {
+					history.Record
+					Change     float64
+					DaysBefore int
+				}{r, change, daysBefore}); err != nil {
+					return err
+				}
+			}
+			return bw.Flush()
+		},
+	}
+}
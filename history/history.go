@@ -0,0 +1,157 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package history stores the observed fares, to see how the prices change.
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+// DB is the fare history database.
+type DB struct {
+	db *bolt.DB
+}
+
+// Query is the search which resulted in the fares.
+type Query struct {
+	Origin      string `json:"origin"`
+	Destination string `json:"destination,omitempty"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Currency    string `json:"currency"`
+}
+
+// Record is an observed fare.
+type Record struct {
+	Observed time.Time `json:"observed"`
+	Query    Query     `json:"query"`
+	airline.Fare
+}
+
+// DefaultPath returns the path of the database under the user's data dir
+// ($XDG_DATA_HOME/fly/history.db).
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "fly", "history.db"), nil
+}
+
+// Open the database, creating it if needed.
+func Open(path string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0640, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open %q: %w", path, err)
+	}
+	return &DB{db: db}, nil
+}
+
+func (db *DB) Close() error {
+	if db == nil || db.db == nil {
+		return nil
+	}
+	return db.db.Close()
+}
+
+// Record the fares, as observed by the query.
+//
+// The fares are stored in a bucket per route, keyed by the day, the observation time, the source and the departure.
+func (db *DB) Record(observed time.Time, q Query, fares []airline.Fare) error {
+	if len(fares) == 0 {
+		return nil
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		for _, f := range fares {
+			b, err := tx.CreateBucketIfNotExists([]byte(Route(f.Origin, f.Destination)))
+			if err != nil {
+				return err
+			}
+			v, err := json.Marshal(Record{Observed: observed, Query: q, Fare: f})
+			if err != nil {
+				return err
+			}
+			if err = b.Put(recordKey(f, observed), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Route returns the records of the route (origin-destination), ordered by day and observation time.
+//
+// If day is not empty, only the records of that day are returned.
+func (db *DB) Route(origin, destination, day string) ([]Record, error) {
+	var records []Record
+	err := db.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(Route(origin, destination)))
+		if b == nil {
+			return nil
+		}
+		prefix := []byte(day)
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var r Record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+			records = append(records, r)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Routes returns the known routes.
+func (db *DB) Routes() ([]string, error) {
+	var routes []string
+	err := db.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			routes = append(routes, string(name))
+			return nil
+		})
+	})
+	return routes, err
+}
+
+// ErrBadRoute is returned by ParseRoute for routes not in ORIGIN-DESTINATION form.
+var ErrBadRoute = errors.New("route should be ORIGIN-DESTINATION")
+
+// Route returns the name of the route.
+func Route(origin, destination string) string { return origin + "-" + destination }
+
+// ParseRoute parses an ORIGIN-DESTINATION route.
+func ParseRoute(s string) (origin, destination string, err error) {
+	origin, destination, found := strings.Cut(s, "-")
+	if !found || origin == "" || destination == "" {
+		return "", "", fmt.Errorf("%q: %w", s, ErrBadRoute)
+	}
+	return strings.ToUpper(origin), strings.ToUpper(destination), nil
+}
+
+func recordKey(f airline.Fare, observed time.Time) []byte {
+	return []byte(f.Day + "\x00" +
+		observed.UTC().Format("2006-01-02T15:04:05.000000000Z") + "\x00" +
+		f.Source + "\x00" + f.Departure.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+func TestRecord(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	dep := time.Date(2024, 10, 20, 6, 0, 0, 0, time.UTC)
+	fare := func(day string, price float64) airline.Fare {
+		return airline.Fare{
+			Source: "ryanair", Origin: "BUD", Destination: "STN",
+			Day: day, Departure: dep, Price: airline.NewMoney(price, "EUR"),
+		}
+	}
+	q := Query{Origin: "BUD", From: "2024-10-20", To: "2024-10-21", Currency: "EUR"}
+	first := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	if err := db.Record(first.Add(time.Hour), q, []airline.Fare{fare("2024-10-20", 30), fare("2024-10-21", 25)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Record(first, q, []airline.Fare{fare("2024-10-20", 40)}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := db.Route("BUD", "STN", "2024-10-20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, wanted 2", len(records))
+	}
+	if r := records[0]; r.Price != airline.NewMoney(40, "EUR") || !r.Observed.Equal(first) || r.Query != q {
+		t.Errorf("first: got %+v", r)
+	}
+	if records, err = db.Route("BUD", "STN", ""); err != nil || len(records) != 3 {
+		t.Errorf("got %d records (%+v), wanted 3", len(records), err)
+	}
+	if routes, err := db.Routes(); err != nil || len(routes) != 1 || routes[0] != "BUD-STN" {
+		t.Errorf("got routes %v (%+v)", routes, err)
+	}
+}
@@ -6,28 +6,27 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"flag"
 	"fmt"
 	"html/template"
 	"log/slog"
-	"math"
 	"os"
 	"os/signal"
 	"slices"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"golang.org/x/sync/errgroup"
-
 	"github.com/peterbourgon/ff/v3/ffcli"
 
 	"github.com/tgulacsi/fly/airline"
-	"github.com/tgulacsi/fly/easyjet"
+	_ "github.com/tgulacsi/fly/easyjet"
 	"github.com/tgulacsi/fly/gflights"
+	"github.com/tgulacsi/fly/history"
 	"github.com/tgulacsi/fly/iata"
-	"github.com/tgulacsi/fly/ryanair"
+	_ "github.com/tgulacsi/fly/ryanair"
 	"github.com/tgulacsi/fly/wizzair"
 )
 
@@ -42,80 +41,183 @@ func Main() error {
 	defer cancel()
 	ctx = airline.WithLogger(ctx, slog.Default())
 
-	rar := ryanair.Ryanair{Client: airline.NewClient(nil, false)}
-	ej := easyjet.EasyJet{Client: airline.NewClient(nil, true)}
-	wz, err := wizzair.New(ctx, nil)
-	if err != nil {
-		return err
-	}
-	G, err := gflights.New(ctx)
-	if err != nil {
-		return err
-	}
-	airlines := map[string]airline.Airline{
-		"ryanair": rar, "easyjet": ej, "wizzair": wz,
-		"gflights": G,
-	}
-	// airlines = airlines[2:3]
+	var sources sourceFlags
 
 	origin := "BUD"
 	FS := flag.NewFlagSet("destinations", flag.ContinueOnError)
 	FS.StringVar(&origin, "origin", origin, "origin")
 	destinationsCmd := ffcli.Command{Name: "destinations", FlagSet: FS,
 		Exec: func(ctx context.Context, args []string) error {
-			destinations, err := rar.Destinations(ctx, origin)
-			for _, d := range destinations {
-				fmt.Println(d)
+			origins, err := airline.ParseOrigins(origin)
+			if err != nil {
+				return err
 			}
-			return err
+			rar, err := airline.DefaultRegistry.Get(ctx, "ryanair")
+			if err != nil {
+				return err
+			}
+			for _, o := range origins {
+				destinations, err := rar.Destinations(ctx, o.Code)
+				for _, d := range destinations {
+					if len(origins) > 1 {
+						fmt.Printf("%s\t%s\n", o.Code, d)
+					} else {
+						fmt.Println(d)
+					}
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
 		},
 	}
 	currency := "EUR"
 	var under float64
 	FS = flag.NewFlagSet("fares", flag.ContinueOnError)
 	FS.StringVar(&currency, "currency", currency, "currency")
-	FS.StringVar(&origin, "origin", origin, "origin")
+	FS.StringVar(&origin, "origin", origin, "origins, comma separated, each as CODE[:COST[:TRAVEL]] with the ground transfer cost and time (BUD,VIE:30:2h30m)")
 	FS.Float64Var(&under, "under", 50, "list only under this price")
 	flagFaresOut := FS.String("o", "", "output (default stdout)")
-	flagFaresTemplate := FS.String("template", `{{printf "% 3.2f"`+" .Price}}\t{{.Day}}\t{{.Destination.IATACode}} ({{.Destination.Country}}, {{.Destination.Municipality}})\t{{.Airline}}[{{.Source}}]\n",
+	flagFaresFormat := FS.String("format", "template", "output format of the fares and round trips: json, ndjson, csv, table or template")
+	flagFaresHistory := FS.String("history", defaultHistoryPath(), "record the fares into this history database (empty to disable)")
+	flagFaresStrict := FS.Bool("strict", false, "abort on the first failing source, instead of printing the fares of the others")
+	flagFaresStream := FS.Bool("stream", false, "print the fares (in the -format) to stderr as each source (or destination) responds, before the sorted list")
+	var passengers airline.Passengers
+	FS.IntVar(&passengers.Adults, "adults", 1, "number of adults")
+	FS.IntVar(&passengers.Children, "children", 0, "number of children")
+	FS.IntVar(&passengers.Infants, "infants", 0, "number of infants")
+	flagFaresCabin := FS.String("cabin", "economy", "cabin: economy, premium, business or first")
+	flagFaresStops := FS.Int("stops", 0, "maximal number of stops (-1 for any)")
+	flagFaresFlex := FS.Int("flex", 0, "search this many days before and after the date")
+	flagFaresReturn := FS.String("return", "", "return date (round trip)")
+	flagFaresStay := FS.String("stay", "", "stay length in days, as MIN-MAX (round trip)")
+	flagFaresRTTemplate := FS.String("rt-template", `{{printf "% 3.2f"`+" .Effective}}\t{{.Outbound.Day}}\t{{.Inbound.Day}}\t{{.Outbound.Origin}}-{{.Outbound.Destination}} ({{.Destination.Country}}, {{.Destination.Municipality}})\t{{.Outbound.Airline}}[{{.Outbound.Source}}]\t{{.Inbound.Airline}}[{{.Inbound.Source}}]\n",
+		"template for printing round trips (with -format template)")
+	flagFaresTemplate := FS.String("template", `{{printf "% 3.2f"`+" .Effective}}\t{{.Day}}\t{{.Origin}}-{{.Destination.IATACode}} ({{.Destination.Country}}, {{.Destination.Municipality}})\t{{.Airline}}[{{.Source}}]"+
+		"{{if not .MemberPrice.IsZero}}\tmember {{printf \"%.2f\" .MemberPrice}}{{end}}{{if not .RegularPrice.IsZero}}\tregular {{printf \"%.2f\" .RegularPrice}}{{end}}"+
+		"{{if not .OriginalPrice.IsZero}}\twas {{printf \"%.2f\" .OriginalPrice}}{{end}}\n",
 		"template for printing")
 	faresCmd := ffcli.Command{Name: "fares", FlagSet: FS,
 		Exec: func(ctx context.Context, args []string) error {
 			if len(args) < 1 {
-				return fmt.Errorf("need date, got only %d", len(args))
+				return fmt.Errorf("need date (or FROM..TO date range), got only %d", len(args))
+			}
+			airlines, err := sources.open(ctx)
+			if err != nil {
+				return err
 			}
-			tmpl := template.Must(template.New("print").Parse(*flagFaresTemplate))
 			out := os.Stdout
 			if *flagFaresOut != "" && *flagFaresOut != "-" {
-				var err error
 				if out, err = os.Create(*flagFaresOut); err != nil {
 					return err
 				}
 			}
 			bw := bufio.NewWriter(out)
-			departDate, err := time.ParseInLocation("20060102", strings.Map(func(r rune) rune {
-				if '0' <= r && r <= '9' {
-					return r
-				}
-				return -1
-			}, args[0])[:8], time.Local)
+			fw, err := newFareWriter(bw, *flagFaresFormat, *flagFaresTemplate)
+			if err != nil {
+				return err
+			}
+			dateRange, err := parseDateRange(args[0])
+			if err != nil {
+				return err
+			}
+			if *flagFaresFlex > 0 {
+				dateRange.From = dateRange.From.AddDate(0, 0, -*flagFaresFlex)
+				dateRange.To = dateRange.To.AddDate(0, 0, *flagFaresFlex)
+			}
+			origins, err := airline.ParseOrigins(origin)
+			if err != nil {
+				return err
+			}
+			underPrice := airline.NewMoney(under, currency)
 			var destination string
 			if len(args) > 1 {
 				destination = args[1]
 			}
-			if err != nil {
-				return fmt.Errorf("parse %q as 2006-01-02: %w", args[1], err)
+			if *flagFaresReturn != "" || *flagFaresStay != "" {
+				if !dateRange.From.Equal(dateRange.To) {
+					return fmt.Errorf("round trip needs one outbound date, got %s..%s",
+						dateRange.From.Format("2006-01-02"), dateRange.To.Format("2006-01-02"))
+				}
+				if !passengers.IsZero() {
+					return fmt.Errorf("round trips are searched for one adult, got %+v", passengers)
+				}
+				if cabin, err := airline.ParseCabin(*flagFaresCabin); err != nil {
+					return err
+				} else if cabin != airline.Economy {
+					return fmt.Errorf("round trips are searched in economy, got %q", cabin)
+				}
+				req := airline.RoundTripRequest{
+					Destination: destination,
+					Outbound:    dateRange.From, Currency: currency,
+				}
+				if *flagFaresReturn != "" {
+					if req.Return, err = parseDate(*flagFaresReturn); err != nil {
+						return err
+					}
+				} else if req.MinStay, req.MaxStay, err = parseRange(*flagFaresStay); err != nil {
+					return fmt.Errorf("parse stay %q: %w", *flagFaresStay, err)
+				}
+				tw, err := newTripWriter(bw, *flagFaresFormat, *flagFaresRTTemplate)
+				if err != nil {
+					return err
+				}
+				var trips []airline.RoundTrip
+				for _, o := range origins {
+					req.Origin = o.Code
+					local, err := airline.RoundTrips(ctx, airlines, req)
+					if err != nil {
+						slog.Warn("round trips", "origin", o.Code, "error", err)
+					}
+					trips = append(trips, local...)
+				}
+				// there and back again
+				effective := func(rt airline.RoundTrip) airline.Money {
+					p, _ := rt.Price.Add(origins.Get(rt.Outbound.Origin).CostIn(rt.Price.Currency).Mul(2))
+					return p
+				}
+				slices.SortStableFunc(trips, func(a, b airline.RoundTrip) int {
+					return cmp.Or(effective(a).Cmp(effective(b)), airline.CmpRoundTrip(a, b))
+				})
+				var found bool
+				for _, rt := range trips {
+					if effective(rt).Cmp(underPrice) > 0 {
+						continue
+					}
+					if err := tw.Write(tripOut{
+						RoundTrip: rt, Destination: iata.Get(rt.Outbound.Destination),
+						Transfer: origins.Get(rt.Outbound.Origin), Effective: effective(rt),
+					}); err != nil {
+						return err
+					}
+					found = true
+				}
+				if !found {
+					slog.Warn("No round trip found", "under", under)
+				}
+				if err := tw.Close(); err != nil {
+					return err
+				}
+				if err := bw.Flush(); err != nil {
+					return err
+				}
+				return out.Close()
 			}
+			conv := airline.CtxConverter(ctx)
 			cmpFare := func(a, b airline.Fare) int {
-				if a.Currency != b.Currency {
-					slog.Warn("currency mismatch", "a", a, "b", b)
-				} else {
-					if a.Price < b.Price {
-						return -1
-					} else if a.Price > b.Price {
-						return 1
+				if a.Price.Currency != b.Price.Currency && conv != nil {
+					pa, errA := a.Price.Convert(ctx, currency)
+					pb, errB := b.Price.Convert(ctx, currency)
+					if errA == nil && errB == nil {
+						a.Price, b.Price = pa, pb
 					}
 				}
+				if a.Price.Currency != b.Price.Currency {
+					slog.Warn("currency mismatch", "a", a, "b", b)
+				} else if c := origins.Effective(a).Cmp(origins.Effective(b)); c != 0 {
+					return c
+				}
 				if a.Day < b.Day {
 					return -1
 				} else if a.Day > b.Day {
@@ -131,56 +233,74 @@ func Main() error {
 				return 0
 			}
 
-			type Stat struct {
-				Dur time.Duration
-				N   int
-			}
-			stats := make(map[string]Stat, len(airlines))
-			var mu sync.Mutex
-			var fares []airline.Fare
-			grp, grpCtx := errgroup.WithContext(ctx)
-			for name, f := range airlines {
-				f := f
-				grp.Go(func() error {
-					var local []airline.Fare
-					start := time.Now()
-					if destination == "" {
-						local, err = airline.WithAllFares(f).
-							AllFares(grpCtx, origin, departDate, currency)
-					} else {
-						local, err = f.
-							Fares(grpCtx, origin, destination, departDate, currency)
-					}
-					dur := time.Since(start)
-					if err != nil {
-						err = fmt.Errorf("%s: %w", name, err)
+			cabin, err := airline.ParseCabin(*flagFaresCabin)
+			if err != nil {
+				return err
+			}
+			var each func(airline.Result)
+			var streamErr error
+			var sw fareWriter
+			if *flagFaresStream {
+				if sw, err = newFareWriter(os.Stderr, *flagFaresFormat, *flagFaresTemplate); err != nil {
+					return err
+				}
+				// the final result of a search repeats the fares of its partial results
+				type fareKey struct {
+					Source, Origin, Destination, Day string
+					Departure                        int64
+				}
+				printed := make(map[fareKey]struct{})
+				each = func(res airline.Result) {
+					if res.Err != nil {
+						slog.Warn("source failed", "source", res.Source, "origin", res.Origin, "error", res.Err)
 					}
-					slices.SortFunc(local, cmpFare)
-					for i, f := range local {
-						// round to .50
-						f.Price = math.Round(f.Price*2.0) / 2.0
-						local[i] = f
+					for _, f := range res.Fares {
+						if origins.Effective(f).Cmp(underPrice) > 0 || streamErr != nil {
+							continue
+						}
+						k := fareKey{Source: f.Source, Origin: f.Origin, Destination: f.Destination, Day: f.Day, Departure: f.Departure.Unix()}
+						if _, ok := printed[k]; ok {
+							continue
+						}
+						printed[k] = struct{}{}
+						streamErr = sw.Write(newFareOut(f, origins))
 					}
-					mu.Lock()
-					fares = append(fares, local...)
-					stats[name] = Stat{Dur: dur, N: len(local)}
-					mu.Unlock()
-					return err
-				})
+				}
+			}
+			fares, stats, err := streamFares(ctx, airlines, origins.Codes(), airline.SearchRequest{
+				Destination: destination, Departure: dateRange, Currency: currency,
+				Passengers: passengers, Cabin: cabin, MaxStops: *flagFaresStops,
+			}, *flagFaresStrict, each)
+			if sw != nil && streamErr == nil {
+				streamErr = sw.Close()
+			}
+			if streamErr != nil {
+				return streamErr
 			}
-			if err := grp.Wait(); err != nil {
+			if err != nil && (*flagFaresStrict || len(fares) == 0) {
+				logStats(slog.Default(), stats)
 				return err
 			}
+			err = nil
+			if *flagFaresHistory != "" {
+				if err := recordHistory(*flagFaresHistory, history.Query{
+					Origin: strings.Join(origins.Codes(), ","), Destination: destination,
+					From: dateRange.From.Format("2006-01-02"), To: dateRange.To.Format("2006-01-02"),
+					Currency: currency,
+				}, fares); err != nil {
+					slog.Warn("record history", "error", err)
+				}
+			}
 			slices.SortStableFunc(fares, cmpFare)
-			var min float64
+			var min airline.Money
 			var found bool
-			for _, f := range slices.Compact(fares) {
-				if f.Currency != currency {
+			for _, f := range airline.MergeFares(fares) {
+				if f.Price.Currency != currency {
 					slog.Warn("currency mismatch", "wanted", currency, "got", f)
 				}
-				if f.Price > under {
-					if min < under || min > f.Price {
-						min = f.Price
+				if price := origins.Effective(f); price.Cmp(underPrice) > 0 {
+					if min.Cmp(underPrice) < 0 || min.Cmp(price) > 0 {
+						min = price
 					}
 					continue
 				}
@@ -188,17 +308,16 @@ func Main() error {
 					slog.Warn("no destination", "got", f)
 				}
 
-				if err := tmpl.Execute(bw, struct {
-					airline.Fare
-					Destination iata.Airport
-				}{f, iata.Get(f.Destination)},
-				); err != nil {
+				if err := fw.Write(newFareOut(f, origins)); err != nil {
 					return err
 				}
 				found = true
 			}
+			if err := fw.Close(); err != nil {
+				return err
+			}
 			if !found {
-				if min > 0 {
+				if !min.IsZero() {
 					slog.Warn("No flight found", "under", under, "min", min)
 				} else {
 					slog.Warn("No flight found.")
@@ -210,12 +329,175 @@ func Main() error {
 			if closeErr := out.Close(); closeErr != nil && err == nil {
 				err = closeErr
 			}
-			slog.Info("statistics", "stats", stats)
+			logStats(slog.Default(), stats)
 			return err
 		},
 	}
-	app := ffcli.Command{Name: "fly", Subcommands: []*ffcli.Command{
-		&destinationsCmd, &faresCmd,
+	connOpts := airline.ConnectionOptions{City: func(code string) string {
+		if city := gflights.City(code); city != "" {
+			return city
+		}
+		return iata.Get(code).Municipality
 	}}
-	return app.ParseAndRun(ctx, os.Args[1:])
+	FS = flag.NewFlagSet("connections", flag.ContinueOnError)
+	FS.StringVar(&currency, "currency", currency, "currency")
+	FS.StringVar(&origin, "origin", origin, "origins, comma separated, each as CODE[:COST[:TRAVEL]]")
+	FS.Float64Var(&under, "under", 100, "list only under this price")
+	FS.DurationVar(&connOpts.MinConnection, "min-connection", airline.DefaultConnectionOptions.MinConnection, "minimal connection time at the same airport")
+	FS.DurationVar(&connOpts.MinAirportChange, "min-airport-change", airline.DefaultConnectionOptions.MinAirportChange, "minimal connection time when changing airports in the same city")
+	FS.DurationVar(&connOpts.MaxConnection, "max-connection", airline.DefaultConnectionOptions.MaxConnection, "maximal connection time")
+	flagConnTemplate := FS.String("template", `{{printf "% 3.2f"`+" .Price}}\t{{.Duration}}\t{{range $i, $f := .Legs}}{{if $i}} {{end}}{{$f.Origin}}-{{$f.Destination}} {{$f.Departure.Format \"01-02 15:04\"}} {{$f.Airline}}[{{$f.Source}}]{{end}}\n",
+		"template for printing")
+	connectionsCmd := ffcli.Command{Name: "connections", FlagSet: FS,
+		ShortUsage: "connections [flags] DATE[..DATE] DESTINATION",
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) < 2 {
+				return fmt.Errorf("need date and destination, got only %d", len(args))
+			}
+			tmpl := template.Must(template.New("print").Parse(*flagConnTemplate))
+			airlines, err := sources.open(ctx)
+			if err != nil {
+				return err
+			}
+			dateRange, err := parseDateRange(args[0])
+			if err != nil {
+				return err
+			}
+			origins, err := airline.ParseOrigins(origin)
+			if err != nil {
+				return err
+			}
+			var conns []airline.Connection
+			for _, o := range origins {
+				local, err := airline.Connections(ctx, airlines, o.Code, args[1], dateRange, currency, connOpts)
+				if err != nil {
+					slog.Warn("connections", "origin", o.Code, "error", err)
+				}
+				conns = append(conns, local...)
+			}
+			effective := func(c airline.Connection) airline.Money {
+				p, _ := c.Price.Add(origins.Get(c.Origin()).CostIn(c.Price.Currency))
+				return p
+			}
+			slices.SortStableFunc(conns, func(a, b airline.Connection) int {
+				return cmp.Or(effective(a).Cmp(effective(b)), airline.CmpConnection(a, b))
+			})
+			underPrice := airline.NewMoney(under, currency)
+			bw := bufio.NewWriter(os.Stdout)
+			var found bool
+			for _, c := range conns {
+				if effective(c).Cmp(underPrice) > 0 {
+					continue
+				}
+				if err := tmpl.Execute(bw, c); err != nil {
+					return err
+				}
+				found = true
+			}
+			if !found {
+				slog.Warn("No connection found", "under", under)
+			}
+			return bw.Flush()
+		},
+	}
+
+	FS = flag.NewFlagSet("fly", flag.ContinueOnError)
+	flagRates := FS.String("rates", "mnb", "currency exchange rates: mnb, ecb or a JSON file")
+	flagMaxConcurrent := FS.Int("max-concurrent", 16, "maximal number of concurrent HTTP requests (0 for unlimited)")
+	flagRate := FS.Float64("rate", 5, "maximal number of HTTP requests per second, per host (0 for unlimited)")
+	flagBurst := FS.Int("burst", 10, "number of HTTP requests per host allowed at once, over -rate")
+	flagRetries := FS.Int("retries", 3, "number of retries of the throttled or failed HTTP requests")
+	flagNoCache := FS.Bool("no-cache", false, "do not use (nor fill) the HTTP cache")
+	FS.BoolVar(&wizzair.DefaultOptions.Timetable, "wizzair-timetable", false, "ask Wizz Air for the fares of each day of each route, with exact times (slower)")
+	FS.BoolVar(&wizzair.DefaultOptions.Member, "wizzair-member", false, "use the Wizz Discount Club member prices of Wizz Air (also for -under)")
+	FS.StringVar(&wizzair.DefaultOptions.Bundle, "wizzair-bundle", "basic", "fare bundle of the Wizz Air prices: basic, middle or plus (the latter two only with -wizzair-timetable)")
+	sources.register(FS)
+	app := ffcli.Command{Name: "fly", FlagSet: FS, Subcommands: []*ffcli.Command{
+		&destinationsCmd, &faresCmd, &connectionsCmd, newHistoryCmd(), newWatchCmd(sources.open),
+		newServeCmd(sources.open), newCacheCmd(),
+	}}
+	if err := app.Parse(os.Args[1:]); err != nil {
+		return err
+	}
+	if err := wizzair.DefaultOptions.Check(); err != nil {
+		return err
+	}
+	airline.DefaultClientOptions = []airline.ClientOption{
+		airline.WithMaxConcurrent(*flagMaxConcurrent),
+		airline.WithRateLimit(*flagRate, *flagBurst),
+		airline.WithRetry(*flagRetries, time.Second),
+	}
+	if *flagNoCache {
+		airline.DefaultClientOptions = append(airline.DefaultClientOptions, airline.WithoutCache())
+	}
+	switch *flagRates {
+	case "mnb":
+		ctx = airline.WithConverter(ctx, airline.NewMNBConverter(slog.Default()))
+	case "ecb":
+		ctx = airline.WithConverter(ctx, airline.NewECBConverter(airline.NewClient(nil, false)))
+	default:
+		rates, err := airline.LoadRates(*flagRates)
+		if err != nil {
+			return err
+		}
+		ctx = airline.WithConverter(ctx, rates)
+	}
+	return app.Run(ctx)
+}
+
+func parseDate(s string) (time.Time, error) {
+	digits := strings.Map(func(r rune) rune {
+		if '0' <= r && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	if len(digits) < 8 {
+		return time.Time{}, fmt.Errorf("parse %q as 2006-01-02: too short", s)
+	}
+	t, err := time.ParseInLocation("20060102", digits[:8], time.Local)
+	if err != nil {
+		return t, fmt.Errorf("parse %q as 2006-01-02: %w", s, err)
+	}
+	return t, nil
+}
+
+// parseDateRange parses "2006-01-02" or "2006-01-02..2006-01-31".
+func parseDateRange(s string) (airline.DateRange, error) {
+	a, b, found := strings.Cut(s, "..")
+	from, err := parseDate(a)
+	if err != nil || !found {
+		return airline.Day(from), err
+	}
+	to, err := parseDate(b)
+	if err == nil && to.Before(from) {
+		err = fmt.Errorf("%q: end before start", s)
+	}
+	return airline.DateRange{From: from, To: to}, err
+}
+
+// parseRange parses "N" or "MIN-MAX".
+func parseRange(s string) (int, int, error) {
+	a, b, found := strings.Cut(s, "-")
+	min, err := strconv.Atoi(strings.TrimSpace(a))
+	if err != nil || !found {
+		return min, min, err
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(b))
+	if err == nil && max < min {
+		err = fmt.Errorf("%d > %d", min, max)
+	}
+	return min, max, err
+}
+
+func recordHistory(path string, q history.Query, fares []airline.Fare) error {
+	db, err := history.Open(path)
+	if err != nil {
+		return err
+	}
+	err = db.Record(time.Now(), q, fares)
+	if closeErr := db.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
 }
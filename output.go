@@ -0,0 +1,392 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+	"github.com/tgulacsi/fly/iata"
+)
+
+// fareOut is what is printed for a fare.
+//
+// Destination shadows Fare.Destination, for the templates.
+type fareOut struct {
+	airline.Fare
+	Destination iata.Airport
+	Transfer    airline.Origin
+	Effective   airline.Money
+}
+
+func newFareOut(f airline.Fare, origins airline.Origins) fareOut {
+	return fareOut{Fare: f, Destination: iata.Get(f.Destination), Transfer: origins.Get(f.Origin), Effective: origins.Effective(f)}
+}
+
+// tripOut is what is printed for a round trip.
+type tripOut struct {
+	airline.RoundTrip
+	Destination iata.Airport
+	Transfer    airline.Origin
+	Effective   airline.Money
+}
+
+// output is a row of the outputs: a fareOut or a tripOut.
+type output interface {
+	// record returns the stable structure of the json, ndjson and csv outputs.
+	record() valuer
+}
+
+type valuer interface {
+	// values returns the values of the named columns.
+	values(names []string) []string
+}
+
+// fareRecord is the stable structure of the json, ndjson and csv outputs.
+//
+// It has every field of airline.Fare; the csv and table outputs have all but the Segments
+// (the flight numbers, stops and duration of the whole journey are there).
+type fareRecord struct {
+	Departure          string          `json:"departure,omitempty"`
+	Arrival            string          `json:"arrival,omitempty"`
+	Day                string          `json:"day"`
+	Airline            string          `json:"airline"`
+	Source             string          `json:"source"`
+	Currency           string          `json:"currency"`
+	Origin             airportRecord   `json:"origin"`
+	Destination        airportRecord   `json:"destination"`
+	Price              json.Number     `json:"price"`
+	ReturnPrice        json.Number     `json:"returnPrice,omitempty"`
+	Effective          json.Number     `json:"effectivePrice"`
+	TransferCost       json.Number     `json:"transferCost,omitempty"`
+	TransferTravelTime string          `json:"transferTravelTime,omitempty"`
+	FlightNumbers      []string        `json:"flightNumbers,omitempty"`
+	Stops              int             `json:"stops"`
+	Duration           string          `json:"duration,omitempty"`
+	Bundle             string          `json:"bundle,omitempty"`
+	MemberPrice        json.Number     `json:"memberPrice,omitempty"`
+	OriginalPrice      json.Number     `json:"originalPrice,omitempty"`
+	RegularPrice       json.Number     `json:"regularPrice,omitempty"`
+	Sources            []string        `json:"sources,omitempty"`
+	Segments           []segmentRecord `json:"segments,omitempty"`
+}
+
+type segmentRecord struct {
+	Departure    string `json:"departure,omitempty"`
+	Arrival      string `json:"arrival,omitempty"`
+	Airline      string `json:"airline,omitempty"`
+	FlightNumber string `json:"flightNumber,omitempty"`
+	Origin       string `json:"origin"`
+	Destination  string `json:"destination"`
+	Duration     string `json:"duration,omitempty"`
+}
+
+type airportRecord struct {
+	Code         string  `json:"code"`
+	Name         string  `json:"name,omitempty"`
+	Country      string  `json:"country,omitempty"`
+	Municipality string  `json:"municipality,omitempty"`
+	TimeZone     string  `json:"timeZone,omitempty"`
+	Lat          float64 `json:"latitude,omitempty"`
+	Lon          float64 `json:"longitude,omitempty"`
+}
+
+func newAirportRecord(code string) airportRecord {
+	a := iata.Get(code)
+	return airportRecord{
+		Code: code, Name: a.Name,
+		Country: a.Country, Municipality: a.Municipality,
+		TimeZone: a.TimeZone, Lat: a.Lat, Lon: a.Lon,
+	}
+}
+
+func isoTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// amount returns the exact amount as a JSON number, empty for zero.
+func amount(m airline.Money) json.Number {
+	if m.IsZero() {
+		return ""
+	}
+	return json.Number(m.Amount())
+}
+
+func (fo fareOut) Record() fareRecord {
+	r := fareRecord{
+		Departure: isoTime(fo.Fare.Departure), Arrival: isoTime(fo.Fare.Arrival),
+		Day: fo.Day, Airline: fo.Airline, Source: fo.Source,
+		Currency:    fo.Price.Currency,
+		Origin:      newAirportRecord(fo.Fare.Origin),
+		Destination: newAirportRecord(fo.Fare.Destination),
+		Price:       amount(fo.Price), ReturnPrice: amount(fo.ReturnPrice),
+		Effective:     amount(fo.Effective),
+		TransferCost:  amount(fo.Transfer.Cost),
+		FlightNumbers: fo.FlightNumbers, Stops: fo.Stops,
+		Bundle: fo.Bundle, MemberPrice: amount(fo.MemberPrice), OriginalPrice: amount(fo.OriginalPrice), RegularPrice: amount(fo.RegularPrice),
+		Sources: fo.Sources,
+	}
+	if fo.Transfer.Travel != 0 {
+		r.TransferTravelTime = fo.Transfer.Travel.String()
+	}
+	if fo.Fare.Duration != 0 {
+		r.Duration = fo.Fare.Duration.String()
+	}
+	for _, sg := range fo.Segments {
+		sr := segmentRecord{
+			Departure: isoTime(sg.Departure), Arrival: isoTime(sg.Arrival),
+			Airline: sg.Airline, FlightNumber: sg.FlightNumber,
+			Origin: sg.Origin, Destination: sg.Destination,
+		}
+		if sg.Duration != 0 {
+			sr.Duration = sg.Duration.String()
+		}
+		r.Segments = append(r.Segments, sr)
+	}
+	return r
+}
+
+func (fo fareOut) record() valuer { return fo.Record() }
+
+// tripRecord is the stable structure of the json, ndjson and csv outputs of a round trip.
+type tripRecord struct {
+	Price     json.Number `json:"price"`
+	Currency  string      `json:"currency"`
+	Effective json.Number `json:"effectivePrice"`
+	Outbound  fareRecord  `json:"outbound"`
+	Inbound   fareRecord  `json:"inbound"`
+}
+
+// Record returns the record of the trip; the effective price (with the transfers) is of the whole trip,
+// the fares have only their own price.
+func (to tripOut) Record() tripRecord {
+	return tripRecord{
+		Price: amount(to.Price), Currency: to.Price.Currency, Effective: amount(to.Effective),
+		Outbound: newFareOut(to.Outbound, nil).Record(),
+		Inbound:  newFareOut(to.Inbound, nil).Record(),
+	}
+}
+
+func (to tripOut) record() valuer { return to.Record() }
+
+// csvColumns are the (stable) columns of the csv and table outputs.
+var csvColumns = []string{
+	"price", "currency", "effective_price", "return_price", "day", "departure", "arrival",
+	"airline", "source",
+	"origin", "origin_name", "origin_country", "origin_municipality", "origin_time_zone", "origin_latitude", "origin_longitude",
+	"destination", "destination_name", "destination_country", "destination_municipality", "destination_time_zone", "destination_latitude", "destination_longitude",
+	"transfer_cost", "transfer_travel_time",
+	"flight_numbers", "stops", "duration", "bundle", "member_price", "original_price", "sources", "regular_price",
+}
+
+// tableColumns are the columns of the table output.
+var tableColumns = []string{
+	"effective_price", "member_price", "regular_price", "original_price", "currency", "day", "departure", "origin",
+	"destination", "destination_country", "destination_municipality", "airline", "flight_numbers", "stops", "sources",
+}
+
+// tripCSVColumns are the (stable) columns of the csv output of the round trips:
+// the price of the trip, and the columns of both fares, prefixed with outbound_ and inbound_.
+var tripCSVColumns = slices.Concat(
+	[]string{"price", "currency", "effective_price"},
+	prefixed("outbound_", csvColumns), prefixed("inbound_", csvColumns),
+)
+
+// tripTableColumns are the columns of the table output of the round trips.
+var tripTableColumns = []string{
+	"effective_price", "currency", "outbound_day", "inbound_day", "outbound_origin",
+	"outbound_destination", "outbound_destination_country", "outbound_destination_municipality",
+	"outbound_airline", "inbound_airline",
+}
+
+func prefixed(prefix string, names []string) []string {
+	pp := make([]string, len(names))
+	for i, nm := range names {
+		pp[i] = prefix + nm
+	}
+	return pp
+}
+
+// pick returns the values of the named columns from all, which has the values of columns.
+func pick(columns, all, names []string) []string {
+	vv := make([]string, len(names))
+	for i, nm := range names {
+		if j := slices.Index(columns, nm); j >= 0 {
+			vv[i] = all[j]
+		}
+	}
+	return vv
+}
+
+func (r fareRecord) values(names []string) []string {
+	return pick(csvColumns, r.columns(), names)
+}
+
+func (r tripRecord) values(names []string) []string {
+	num := func(n json.Number) string { return cmp.Or(n.String(), "0.00") }
+	all := slices.Concat(
+		[]string{num(r.Price), r.Currency, num(r.Effective)},
+		r.Outbound.columns(), r.Inbound.columns(),
+	)
+	return pick(tripCSVColumns, all, names)
+}
+
+func (r fareRecord) columns() []string {
+	num := func(n json.Number) string { return cmp.Or(n.String(), "0.00") }
+	coord := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	return []string{
+		num(r.Price), r.Currency, num(r.Effective), num(r.ReturnPrice), r.Day, r.Departure, r.Arrival,
+		r.Airline, r.Source,
+		r.Origin.Code, r.Origin.Name, r.Origin.Country, r.Origin.Municipality, r.Origin.TimeZone, coord(r.Origin.Lat), coord(r.Origin.Lon),
+		r.Destination.Code, r.Destination.Name, r.Destination.Country, r.Destination.Municipality, r.Destination.TimeZone, coord(r.Destination.Lat), coord(r.Destination.Lon),
+		num(r.TransferCost), r.TransferTravelTime,
+		strings.Join(r.FlightNumbers, " "), strconv.Itoa(r.Stops), r.Duration, r.Bundle, num(r.MemberPrice), num(r.OriginalPrice),
+		strings.Join(r.Sources, ","), num(r.RegularPrice),
+	}
+}
+
+// fareWriter writes the fares (or round trips) in some format.
+type fareWriter interface {
+	Write(output) error
+	// Close finishes the output, but does not close the underlying writer.
+	Close() error
+}
+
+func newFareWriter(w io.Writer, format, tmpl string) (fareWriter, error) {
+	return newWriter(w, format, tmpl, csvColumns, tableColumns)
+}
+
+// newTripWriter returns a writer of the round trips (tripOut).
+func newTripWriter(w io.Writer, format, tmpl string) (fareWriter, error) {
+	return newWriter(w, format, tmpl, tripCSVColumns, tripTableColumns)
+}
+
+func newWriter(w io.Writer, format, tmpl string, csvColumns, tableColumns []string) (fareWriter, error) {
+	switch format {
+	case "", "template":
+		t, err := template.New("print").Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		return templateWriter{w: w, tmpl: t}, nil
+	case "json":
+		return &jsonWriter{w: w}, nil
+	case "ndjson":
+		return ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w), columns: csvColumns}, nil
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		return &csvWriter{w: tabRows{tw}, columns: tableColumns, flush: tw.Flush}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (json, ndjson, csv, table or template)", format)
+}
+
+type templateWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func (tw templateWriter) Write(o output) error { return tw.tmpl.Execute(tw.w, o) }
+func (tw templateWriter) Close() error         { return nil }
+
+type jsonWriter struct {
+	w       io.Writer
+	records []valuer
+}
+
+func (jw *jsonWriter) Write(o output) error {
+	jw.records = append(jw.records, o.record())
+	return nil
+}
+func (jw *jsonWriter) Close() error {
+	if jw.records == nil {
+		jw.records = []valuer{}
+	}
+	enc := json.NewEncoder(jw.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jw.records)
+}
+
+type ndjsonWriter struct{ enc *json.Encoder }
+
+func (nw ndjsonWriter) Write(o output) error { return nw.enc.Encode(o.record()) }
+func (nw ndjsonWriter) Close() error         { return nil }
+
+type rowWriter interface {
+	Write([]string) error
+	Flush()
+	Error() error
+}
+
+type csvWriter struct {
+	w       rowWriter
+	flush   func() error
+	columns []string
+	header  bool
+}
+
+func (cw *csvWriter) writeHeader() error {
+	if cw.header {
+		return nil
+	}
+	cw.header = true
+	return cw.w.Write(cw.columns)
+}
+
+func (cw *csvWriter) Write(o output) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	return cw.w.Write(o.record().values(cw.columns))
+}
+func (cw *csvWriter) Close() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	if err := cw.w.Error(); err != nil {
+		return err
+	}
+	if cw.flush != nil {
+		return cw.flush()
+	}
+	return nil
+}
+
+// tabRows writes tab separated rows, for the tabwriter.
+type tabRows struct {
+	w io.Writer
+}
+
+func (cl tabRows) Write(row []string) error {
+	for i, s := range row {
+		if i != 0 {
+			if _, err := io.WriteString(cl.w, "\t"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(cl.w, s); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(cl.w, "\n")
+	return err
+}
+func (cl tabRows) Flush()       {}
+func (cl tabRows) Error() error { return nil }
@@ -0,0 +1,134 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+func TestFareWriter(t *testing.T) {
+	budapest, _ := time.LoadLocation("Europe/Budapest")
+	f := airline.Fare{
+		Airline: "Ryanair", Source: "ryanair", Origin: "BUD", Destination: "STN",
+		Day: "2024-10-20", Departure: time.Date(2024, 10, 20, 6, 0, 0, 0, budapest),
+		Price: airline.NewMoney(19.99, "EUR"),
+	}
+	origins := airline.Origins{{Code: "BUD", Cost: airline.NewMoney(10, "")}}
+	for _, format := range []string{"json", "ndjson", "csv", "table", "template"} {
+		var buf strings.Builder
+		fw, err := newFareWriter(&buf, format, "{{.Effective}} {{.Destination.Municipality}}\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = fw.Write(newFareOut(f, origins)); err != nil {
+			t.Fatal(err)
+		}
+		if err = fw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		s := buf.String()
+		switch format {
+		case "json", "ndjson":
+			var r fareRecord
+			if format == "json" {
+				var rr []fareRecord
+				err = json.Unmarshal([]byte(s), &rr)
+				if len(rr) == 1 {
+					r = rr[0]
+				}
+			} else {
+				err = json.Unmarshal([]byte(s), &r)
+			}
+			if err != nil {
+				t.Fatalf("%s: %+v", format, err)
+			}
+			if r.Departure != "2024-10-20T06:00:00+02:00" || r.Destination.TimeZone != "Europe/London" || r.Effective != "29.99" {
+				t.Errorf("%s: got %+v", format, r)
+			}
+		case "csv":
+			rows, err := csv.NewReader(strings.NewReader(s)).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 2 || strings.Join(rows[0], ",") != strings.Join(csvColumns, ",") || len(rows[1]) != len(csvColumns) {
+				t.Errorf("csv: got %q", rows)
+			}
+		case "table":
+			if lines := strings.Split(strings.TrimSpace(s), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[0], "effective_price") {
+				t.Errorf("table: got %q", s)
+			}
+		case "template":
+			if s != "29.99 EUR London\n" {
+				t.Errorf("template: got %q", s)
+			}
+		}
+	}
+}
+
+func TestTripWriter(t *testing.T) {
+	budapest, _ := time.LoadLocation("Europe/Budapest")
+	out := airline.Fare{
+		Airline: "Ryanair", Source: "ryanair", Origin: "BUD", Destination: "STN",
+		Day: "2024-10-20", Departure: time.Date(2024, 10, 20, 6, 0, 0, 0, budapest),
+		Price: airline.NewMoney(19.99, "EUR"),
+		Segments: []airline.Segment{{
+			Departure: time.Date(2024, 10, 20, 6, 0, 0, 0, budapest),
+			Airline:   "Ryanair", FlightNumber: "FR2201", Origin: "BUD", Destination: "STN",
+			Duration: 150 * time.Minute,
+		}},
+	}
+	in := out
+	in.Origin, in.Destination, in.Day, in.Segments = "STN", "BUD", "2024-10-23", nil
+	to := tripOut{
+		RoundTrip: airline.RoundTrip{Outbound: out, Inbound: in, Price: airline.NewMoney(39.98, "EUR")},
+		Transfer:  airline.Origin{Code: "BUD", Cost: airline.NewMoney(10, "")},
+		Effective: airline.NewMoney(59.98, "EUR"),
+	}
+	for _, format := range []string{"ndjson", "csv", "table"} {
+		var buf strings.Builder
+		tw, err := newTripWriter(&buf, format, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tw.Write(to); err != nil {
+			t.Fatal(err)
+		}
+		if err = tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		s := buf.String()
+		switch format {
+		case "ndjson":
+			var r tripRecord
+			if err = json.Unmarshal([]byte(s), &r); err != nil {
+				t.Fatal(err)
+			}
+			if r.Effective != "59.98" || r.Inbound.Day != "2024-10-23" ||
+				len(r.Outbound.Segments) != 1 || r.Outbound.Segments[0].FlightNumber != "FR2201" || r.Outbound.Segments[0].Duration != "2h30m0s" {
+				t.Errorf("ndjson: got %+v", r)
+			}
+		case "csv":
+			rows, err := csv.NewReader(strings.NewReader(s)).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 2 || len(rows[1]) != len(tripCSVColumns) || rows[1][2] != "59.98" ||
+				rows[1][slices.Index(tripCSVColumns, "inbound_day")] != "2024-10-23" {
+				t.Errorf("csv: got %q", rows)
+			}
+		case "table":
+			if lines := strings.Split(strings.TrimSpace(s), "\n"); len(lines) != 2 || !strings.Contains(lines[1], "2024-10-23") {
+				t.Errorf("table: got %q", s)
+			}
+		}
+	}
+}
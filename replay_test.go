@@ -0,0 +1,84 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+	"github.com/tgulacsi/fly/easyjet"
+	"github.com/tgulacsi/fly/ryanair"
+	"github.com/tgulacsi/fly/wizzair"
+)
+
+// TestFaresReplay runs the fares search with the recorded responses of the sources.
+func TestFaresReplay(t *testing.T) {
+	t.Setenv("FLY_REPLAY", strings.Join([]string{
+		filepath.Join("ryanair", "testdata", "replay"),
+		filepath.Join("easyjet", "testdata", "replay"),
+		filepath.Join("wizzair", "testdata", "replay"),
+	}, string(os.PathListSeparator)))
+	ctx := context.Background()
+	wz, err := wizzair.New(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	airlines := map[string]airline.Airline{
+		"ryanair": ryanair.Ryanair{Client: airline.NewClient(nil, false)},
+		"easyjet": easyjet.EasyJet{Client: airline.NewClient(nil, true)},
+		"wizzair": wz,
+	}
+
+	day := time.Date(2024, 10, 15, 0, 0, 0, 0, time.Local)
+	fares, stats, err := searchFares(ctx, airlines, []string{"BUD"}, airline.SearchRequest{
+		Departure: airline.Day(day), Currency: "EUR",
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int{"ryanair": 2, "easyjet": 1, "wizzair": 0} {
+		if got := stats[name].N; got != want {
+			t.Errorf("%s: got %d fares, wanted %d", name, got, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	fw, err := newFareWriter(&buf, "ndjson", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(fares, func(a, b airline.Fare) int { return strings.Compare(a.Destination, b.Destination) })
+	for _, f := range fares {
+		if err := fw.Write(newFareOut(f, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r fareRecord
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Day != "2024-10-15" || r.Currency != "EUR" {
+			t.Errorf("got %+v", r)
+		}
+		got = append(got, r.Destination.Code+":"+r.Source)
+	}
+	if want := []string{"BGY:ryanair", "LGW:easyjet", "STN:ryanair"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
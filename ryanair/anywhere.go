@@ -0,0 +1,134 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ryanair
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+var _ airline.AirlineAllFares = Ryanair{}
+var _ airline.AllRanger = Ryanair{}
+
+const anywhereURL = `https://www.ryanair.com/api/farfnd/v4/oneWayFares`
+
+// AnywhereFare is a fare of the "from origin to anywhere" search.
+type AnywhereFare struct {
+	Outbound struct {
+		DepartureAirport FareAirport `json:"departureAirport"`
+		ArrivalAirport   FareAirport `json:"arrivalAirport"`
+		Departure        string      `json:"departureDate"`
+		Arrival          string      `json:"arrivalDate"`
+		FlightNumber     string      `json:"flightNumber"`
+		Price            Price       `json:"price"`
+	} `json:"outbound"`
+}
+
+// FareAirport is an airport of an AnywhereFare.
+type FareAirport struct {
+	Code    string `json:"iataCode"`
+	Name    string `json:"name"`
+	Country string `json:"countryName"`
+	SEO     string `json:"seoName"`
+}
+
+// AllFares returns the fares of the departure day to all the destinations of origin,
+// with one request to the fare finder, or asking each destination if that fails.
+func (co Ryanair) AllFares(ctx context.Context, origin string, departDate time.Time, currency string) ([]airline.Fare, error) {
+	return co.AllFaresInRange(ctx, origin, airline.Day(departDate), currency)
+}
+
+// AllFaresInRange returns the fares of the date range to all the destinations of origin,
+// with one request to the fare finder for each day.
+// The days the fare finder fails for are asked from each destination,
+// which fetches each month only once for the whole range.
+func (co Ryanair) AllFaresInRange(ctx context.Context, origin string, dr airline.DateRange, currency string) ([]airline.Fare, error) {
+	var fares []airline.Fare
+	var failed []time.Time
+	for _, day := range dr.Days() {
+		ff, err := co.AnywhereFares(ctx, origin, airline.Day(day), currency, 0)
+		if err != nil {
+			airline.CtxLogger(ctx).Warn("fare finder, asking each destination", "source", sourceName, "origin", origin, "day", day.Format("2006-01-02"), "error", err)
+			failed = append(failed, day)
+			continue
+		}
+		fares = append(fares, ff...)
+	}
+	if len(failed) == 0 {
+		return fares, nil
+	}
+	// hide AllFares and AllFaresInRange, to ask the range of each destination
+	ff, err := airline.AllFaresInRange(ctx, struct {
+		airline.Airline
+		airline.Ranger
+	}{co, co}, origin, airline.DateRange{From: failed[0], To: failed[len(failed)-1]}, currency)
+	for _, f := range ff {
+		if slices.ContainsFunc(failed, func(day time.Time) bool { return day.Format("2006-01-02") == f.Day }) {
+			fares = append(fares, f)
+		}
+	}
+	return fares, err
+}
+
+// AnywhereFares returns the cheapest fare to each destination of origin in the date range,
+// under maxPrice (if not zero).
+func (co Ryanair) AnywhereFares(ctx context.Context, origin string, dr airline.DateRange, currency string, maxPrice float64) ([]airline.Fare, error) {
+	params := url.Values{
+		"departureAirportIataCode":  {origin},
+		"outboundDepartureDateFrom": {dr.From.Format("2006-01-02")},
+		"outboundDepartureDateTo":   {dr.To.Format("2006-01-02")},
+		"currency":                  {currency},
+	}
+	if maxPrice > 0 {
+		params.Set("priceValueTo", strconv.FormatFloat(maxPrice, 'f', -1, 64))
+	}
+	sr, _, err := co.Client.Get(ctx, anywhereURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Fares []AnywhereFare `json:"fares"`
+	}
+	if err = json.NewDecoder(sr).Decode(&resp); err != nil {
+		return nil, err
+	}
+	ff := make([]airline.Fare, 0, len(resp.Fares))
+	for _, f := range resp.Fares {
+		o := f.Outbound
+		destination := o.ArrivalAirport.Code
+		originTZ, destTZ, err := co.timeZones(ctx, origin, destination)
+		if err != nil {
+			// a new airport should not lose the fares of all the others
+			airline.CtxLogger(ctx).Warn("skip fare", "source", sourceName, "origin", origin, "destination", destination, "error", err)
+			continue
+		}
+		const timePat = "2006-01-02T15:04:05"
+		departure, err := time.ParseInLocation(timePat, o.Departure, originTZ)
+		if err != nil {
+			return ff, err
+		}
+		arrival, err := time.ParseInLocation(timePat, o.Arrival, destTZ)
+		if err != nil {
+			return ff, err
+		}
+		ff = append(ff, airline.Fare{
+			Airline:     airlineName,
+			Source:      sourceName,
+			Origin:      origin,
+			Destination: destination,
+			Day:         departure.Format("2006-01-02"),
+			Price:       o.Price.Money(),
+			Arrival:     arrival,
+			Departure:   departure,
+		}.Direct(o.FlightNumber))
+	}
+	return airline.ConvertFares(ctx, dr.Filter(ff), currency)
+}
@@ -6,13 +6,19 @@
 package ryanair
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	"github.com/tgulacsi/fly/airline"
 	"github.com/tgulacsi/fly/iata"
 )
@@ -22,12 +28,26 @@ const airportsURL = `https://www.ryanair.com/api/views/locate/searchWidget/route
 type Ryanair struct{ Client airline.HTTPClient }
 
 var _ airline.Airline = Ryanair{}
+var _ airline.Ranger = Ryanair{}
+var _ airline.AirlineSearch = Ryanair{}
 
 const (
 	airlineName = "Ryanair"
 	sourceName  = "ryanair"
 )
 
+func init() {
+	airline.Register(sourceName, func(ctx context.Context) (airline.Airline, error) {
+		return Ryanair{Client: airline.NewClient(nil, true, airline.WithCachePolicy(cachePolicy))}, nil
+	})
+}
+
+// cachePolicy caches the routes for a day, the fares for half an hour.
+var cachePolicy = airline.CachePolicy{Name: sourceName, Rules: []airline.CacheRule{
+	{Match: "/searchWidget/routes/", TTL: 24 * time.Hour},
+	{Match: "/farfnd/", TTL: 30 * time.Minute},
+}}
+
 func (co Ryanair) Destinations(ctx context.Context, origin string) ([]string, error) {
 	local, err := co.FullDestinations(ctx, origin)
 	dests := make([]string, len(local))
@@ -37,20 +57,28 @@ func (co Ryanair) Destinations(ctx context.Context, origin string) ([]string, er
 	return dests, err
 }
 
+// destinations remembers the routes of each origin (of each client), as Fares looks them up for every destination.
+var destinations = airline.Memo[airline.ClientKey[string], []ArrivalAirport]{TTL: time.Hour}
+
+// FullDestinations returns the destination airports of origin.
+//
+// The result is shared (remembered for an hour), it must not be modified.
 func (co Ryanair) FullDestinations(ctx context.Context, origin string) ([]ArrivalAirport, error) {
-	sr, _, err := co.Client.Get(ctx, strings.Replace(airportsURL, "{{origin}}", origin, 1))
-	if err != nil {
-		return nil, err
-	}
-	var arrivals []struct {
-		Airport ArrivalAirport `json:"arrivalAirport"`
-	}
-	err = json.NewDecoder(sr).Decode(&arrivals)
-	aa := make([]ArrivalAirport, len(arrivals))
-	for i, a := range arrivals {
-		aa[i] = a.Airport
-	}
-	return aa, err
+	return destinations.Do(ctx, airline.MemoKey(co.Client, origin), func(ctx context.Context) ([]ArrivalAirport, error) {
+		sr, _, err := co.Client.Get(ctx, strings.Replace(airportsURL, "{{origin}}", origin, 1))
+		if err != nil {
+			return nil, err
+		}
+		var arrivals []struct {
+			Airport ArrivalAirport `json:"arrivalAirport"`
+		}
+		err = json.NewDecoder(sr).Decode(&arrivals)
+		aa := make([]ArrivalAirport, len(arrivals))
+		for i, a := range arrivals {
+			aa[i] = a.Airport
+		}
+		return aa, err
+	})
 }
 
 /*
@@ -125,46 +153,116 @@ type Coordinate struct {
 
 const faresURL = `https://www.ryanair.com/api/farfnd/v4/oneWayFares/{{origin}}/{{destination}}/cheapestPerDay?outboundMonthOfDate={{departDate}}&currency={{currency}}`
 
+// Fares returns the fares of the departure day.
 func (co Ryanair) Fares(ctx context.Context, origin, destination string, departDate time.Time, currency string) ([]airline.Fare, error) {
-	logger := airline.CtxLogger(ctx)
+	return co.FaresInRange(ctx, origin, destination, airline.Day(departDate), currency)
+}
 
-	destTZ := iata.Get(destination).Location
-	originTZ := iata.Get(origin).Location
-	if originTZ == nil || destTZ == nil {
-		airports, err := co.FullDestinations(ctx, origin)
+// FaresInRange returns the fares in the date range, asking for each month it touches concurrently.
+func (co Ryanair) FaresInRange(ctx context.Context, origin, destination string, dr airline.DateRange, currency string) ([]airline.Fare, error) {
+	originTZ, destTZ, err := co.timeZones(ctx, origin, destination)
+	if err != nil {
+		return nil, err
+	}
+	grp, grpCtx := errgroup.WithContext(ctx)
+	grp.SetLimit(4)
+	var mu sync.Mutex
+	var ff []airline.Fare
+	for _, month := range months(dr) {
+		grp.Go(func() error {
+			local, err := co.monthFares(grpCtx, origin, destination, month, currency, originTZ, destTZ)
+			mu.Lock()
+			ff = append(ff, local...)
+			mu.Unlock()
+			return err
+		})
+	}
+	err = grp.Wait()
+	ff = dr.Filter(ff)
+	slices.SortFunc(ff, func(a, b airline.Fare) int { return a.Departure.Compare(b.Departure) })
+	if err != nil {
+		return ff, err
+	}
+	return airline.ConvertFares(ctx, ff, currency)
+}
+
+// SoldOut returns the days in the date range when the flights of the route are sold out.
+//
+// They are not in the fares, as a sold-out day cannot be booked at its (last) price.
+// The currency is only for sharing the responses with FaresInRange.
+func (co Ryanair) SoldOut(ctx context.Context, origin, destination string, dr airline.DateRange, currency string) ([]string, error) {
+	var days []string
+	for _, month := range months(dr) {
+		fares, err := co.cheapestPerDay(ctx, origin, destination, month, currency)
 		if err != nil {
-			return nil, err
+			return days, err
 		}
-		for _, a := range airports {
-			if destTZ == nil && a.Code == destination {
-				if destTZ, err = time.LoadLocation(a.TimeZone); err != nil {
-					return nil, err
-				}
+		for _, f := range fares {
+			if f.SoldOut && dr.Contains(f.Day) {
+				days = append(days, f.Day)
 			}
-			if originTZ != nil {
-				continue
+		}
+	}
+	return days, nil
+}
+
+// months returns the first days of the months the date range touches.
+func months(dr airline.DateRange) []time.Time {
+	var months []time.Time
+	for m := time.Date(dr.From.Year(), dr.From.Month(), 1, 0, 0, 0, 0, dr.From.Location()); !m.After(dr.To); m = m.AddDate(0, 1, 0) {
+		months = append(months, m)
+	}
+	return months
+}
+
+// timeZones returns the time zones of the airports, from the iata package or the routes.
+func (co Ryanair) timeZones(ctx context.Context, origin, destination string) (originTZ, destTZ *time.Location, err error) {
+	destTZ = iata.Get(destination).Location
+	originTZ = iata.Get(origin).Location
+	if originTZ != nil && destTZ != nil {
+		return originTZ, destTZ, nil
+	}
+	airports, err := co.FullDestinations(ctx, origin)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, a := range airports {
+		if destTZ == nil && a.Code == destination {
+			if destTZ, err = time.LoadLocation(a.TimeZone); err != nil {
+				return nil, nil, err
 			}
-			backs, _ := co.FullDestinations(ctx, a.Code)
-			for _, a := range backs {
-				if a.Code == origin {
-					if originTZ, err = time.LoadLocation(a.TimeZone); err != nil {
-						return nil, err
-					}
-					break
+		}
+		if originTZ != nil {
+			continue
+		}
+		backs, _ := co.FullDestinations(ctx, a.Code)
+		for _, a := range backs {
+			if a.Code == origin {
+				if originTZ, err = time.LoadLocation(a.TimeZone); err != nil {
+					return nil, nil, err
 				}
+				break
 			}
 		}
 	}
+	if originTZ == nil || destTZ == nil {
+		return nil, nil, fmt.Errorf("%s-%s: unknown time zone", origin, destination)
+	}
+	return originTZ, destTZ, nil
+}
 
-	var ff []airline.Fare
+// cheapestPerDay returns the cheapest fare of each day of the month.
+func (co Ryanair) cheapestPerDay(ctx context.Context, origin, destination string, month time.Time, currency string) ([]Fare, error) {
+	logger := airline.CtxLogger(ctx)
+	// the whole month is returned, so ask for its first day, for the sake of the cache
 	sr, _, err := co.Client.Get(ctx, strings.NewReplacer(
 		"{{origin}}", origin,
 		"{{destination}}", destination,
 		"{{currency}}", currency,
-		"{{departDate}}", departDate.Format("2006-01-02"),
+		"{{departDate}}", month.Format("2006-01-02"),
 	).Replace(faresURL))
 	if err != nil {
-		return ff, err
+		return nil, err
 	}
 	var fares struct {
 		Outbound struct {
@@ -176,10 +274,20 @@ func (co Ryanair) Fares(ctx context.Context, origin, destination string, departD
 	if logger.Enabled(ctx, slog.LevelDebug) {
 		logger.Debug(buf.String())
 	}
-	if err = json.NewDecoder(sr).Decode(&fares); err != nil {
-		return ff, err
+	err = json.NewDecoder(sr).Decode(&fares)
+	return fares.Outbound.Fares, err
+}
+
+// monthFares returns the fares of the whole month, not converted to currency.
+//
+// cheapestPerDay has no flight numbers, and the sold-out days are left out (see SoldOut).
+func (co Ryanair) monthFares(ctx context.Context, origin, destination string, month time.Time, currency string, originTZ, destTZ *time.Location) ([]airline.Fare, error) {
+	fares, err := co.cheapestPerDay(ctx, origin, destination, month, currency)
+	if err != nil {
+		return nil, err
 	}
-	for _, f := range fares.Outbound.Fares {
+	var ff []airline.Fare
+	for _, f := range fares {
 		if f.Unavailable || f.SoldOut || f.Departure == "" {
 			continue
 		}
@@ -198,15 +306,19 @@ func (co Ryanair) Fares(ctx context.Context, origin, destination string, departD
 			Origin:      origin,
 			Destination: destination,
 			Day:         f.Day,
-			Price:       f.Price.Value,
-			Currency:    f.Price.Currency,
+			Price:       f.Price.Money(),
 			Arrival:     arrival,
 			Departure:   departure,
-		})
+		}.Direct(""))
 	}
 	return ff, nil
 }
 
+// Search is airline.SearchWith the options of cheapestPerDay and the fare finder.
+func (co Ryanair) Search(ctx context.Context, req airline.SearchRequest) ([]airline.Fare, error) {
+	return airline.SearchWith(ctx, co, req, airline.OptFares)
+}
+
 type Fare struct {
 	Day         string `json:"day"`
 	Arrival     string `json:"arrivalDate"`
@@ -222,3 +334,13 @@ type Price struct {
 	Symbol              string  `json:"currencySymbol"`
 	Value               float64 `json:"value"`
 }
+
+// Money returns the exact amount from the main and fractional units, or the Value if they are missing.
+func (p Price) Money() airline.Money {
+	if p.ValueMainUnit != "" {
+		if m, err := airline.ParseMoney(p.ValueMainUnit+"."+cmp.Or(p.ValueFractionalUnit, "0"), p.Currency); err == nil {
+			return m
+		}
+	}
+	return airline.NewMoney(p.Value, p.Currency)
+}
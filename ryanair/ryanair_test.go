@@ -0,0 +1,169 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ryanair_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+	"github.com/tgulacsi/fly/ryanair"
+)
+
+func TestReplay(t *testing.T) {
+	t.Setenv("FLY_REPLAY", "testdata/replay")
+	rar := ryanair.Ryanair{Client: airline.NewClient(nil, false)}
+	ctx := context.Background()
+
+	dests, err := rar.Destinations(ctx, "BUD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"STN", "BGY"}; !slices.Equal(dests, want) {
+		t.Errorf("got %q, wanted %q", dests, want)
+	}
+
+	day := time.Date(2024, 10, 15, 0, 0, 0, 0, time.Local)
+	fares, err := rar.Fares(ctx, "BUD", "STN", day, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the asked day
+	if len(fares) != 1 {
+		t.Fatalf("got %d fares, wanted 1: %+v", len(fares), fares)
+	}
+	f := fares[0]
+	if f.Day != "2024-10-15" || f.Price != airline.NewMoney(19.99, "EUR") || f.Source != "ryanair" {
+		t.Errorf("got %+v", f)
+	}
+	if got := f.Departure.In(time.UTC).Format(time.RFC3339); got != "2024-10-15T18:25:00Z" {
+		t.Errorf("departure: got %s, wanted 18:25 UTC (20:25 in Budapest)", got)
+	}
+	if got := f.Arrival.In(time.UTC).Format(time.RFC3339); got != "2024-10-15T20:30:00Z" {
+		t.Errorf("arrival: got %s, wanted 20:30 UTC (21:30 in London)", got)
+	}
+	if f.Duration != 2*time.Hour+5*time.Minute || len(f.Segments) != 1 {
+		t.Errorf("got duration %s, segments %+v, wanted 2h5m direct", f.Duration, f.Segments)
+	}
+
+	// with the fare finder
+	all, err := airline.WithAllFares(rar).AllFares(ctx, "BUD", day, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("got %d fares, wanted 2: %+v", len(all), all)
+	}
+	slices.SortFunc(all, func(a, b airline.Fare) int { return a.Departure.Compare(b.Departure) })
+	if f := all[1]; f.Destination != "STN" || f.Price != airline.NewMoney(19.99, "EUR") || f.Duration != 2*time.Hour+5*time.Minute ||
+		!slices.Equal(f.FlightNumbers, []string{"FR2201"}) {
+		t.Errorf("got %+v", f)
+	}
+	// without the fare finder (no fixture), asking each destination
+	if all, err = rar.AllFares(ctx, "BUD", day.AddDate(0, 0, -1), "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Destination != "STN" || all[0].Price != airline.NewMoney(24.99, "EUR") {
+		t.Errorf("got %+v, wanted STN for 24.99", all)
+	}
+
+	// the window crosses a month boundary, the unavailable and the sold out days are skipped
+	window := airline.DateRange{From: day.AddDate(0, 0, -1), To: time.Date(2024, 11, 2, 0, 0, 0, 0, time.Local)}
+	fares, err = airline.FaresInRange(ctx, rar, "BUD", "STN", window, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var days []string
+	for _, f := range fares {
+		days = append(days, f.Day)
+	}
+	if want := []string{"2024-10-14", "2024-10-15", "2024-11-01"}; !slices.Equal(days, want) {
+		t.Errorf("got %q, wanted %q", days, want)
+	}
+	// the fare finder is asked for each day, its fixture has only the 15th, the others ask each destination
+	if all, err = airline.AllFaresInRange(ctx, rar, "BUD", window, "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	days = days[:0]
+	var numbered int
+	for _, f := range all {
+		days = append(days, f.Day+" "+f.Destination)
+		if len(f.FlightNumbers) != 0 {
+			numbered++
+		}
+	}
+	slices.Sort(days)
+	if want := []string{"2024-10-14 STN", "2024-10-15 BGY", "2024-10-15 STN", "2024-11-01 STN"}; !slices.Equal(days, want) || numbered != 2 {
+		t.Errorf("got %q (%d with flight number), wanted %q (2 from the fare finder)", days, numbered, want)
+	}
+
+	if all, err = rar.Search(ctx, airline.SearchRequest{Origin: "BUD", Departure: airline.Day(day), Currency: "EUR", MaxPrice: airline.NewMoney(15, "EUR")}); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Destination != "BGY" || len(all[0].FlightNumbers) == 0 {
+		t.Errorf("got %+v, wanted BGY from the fare finder", all)
+	}
+
+	if days, err = rar.SoldOut(ctx, "BUD", "STN", window, "EUR"); err != nil {
+		t.Fatal(err)
+	} else if want := []string{"2024-10-17"}; !slices.Equal(days, want) {
+		t.Errorf("sold out: got %q, wanted %q", days, want)
+	}
+}
+
+// counter counts the requests of each URL.
+type counter struct {
+	http.RoundTripper
+	mu   sync.Mutex
+	urls map[string]int
+}
+
+func (c *counter) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.urls[r.URL.String()]++
+	c.mu.Unlock()
+	return c.RoundTripper.RoundTrip(r)
+}
+
+func TestAllFaresInRange(t *testing.T) {
+	c := &counter{RoundTripper: airline.NewReplayTransport("testdata/replay"), urls: make(map[string]int)}
+	rar := ryanair.Ryanair{Client: airline.NewClient(&http.Client{Transport: c}, false, airline.WithRateLimit(0, 0), airline.WithRetry(0, 0))}
+	window := airline.DateRange{
+		From: time.Date(2024, 10, 14, 0, 0, 0, 0, time.Local),
+		To:   time.Date(2024, 11, 2, 0, 0, 0, 0, time.Local),
+	}
+	fares, err := airline.AllFaresInRange(context.Background(), rar, "BUD", window, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fare to the unknown airport is skipped, not the whole response of the 15th
+	var numbered int
+	for _, f := range fares {
+		if len(f.FlightNumbers) != 0 {
+			numbered++
+		}
+	}
+	if len(fares) != 4 || numbered != 2 {
+		t.Errorf("got %d fares (%d from the fare finder), wanted 4 (2)", len(fares), numbered)
+	}
+	// the other days ask each destination, for each month only once
+	var months int
+	for u, n := range c.urls {
+		if strings.Contains(u, "/cheapestPerDay?") {
+			months++
+			if n != 1 {
+				t.Errorf("%s: asked %d times", u, n)
+			}
+		}
+	}
+	if months != 4 {
+		t.Errorf("got %d months, wanted 4: %v", months, c.urls)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+// Stat is the status of the search of one source.
+type Stat struct {
+	Err string `json:",omitempty"`
+	Dur time.Duration
+	N   int
+}
+
+// searchFares searches the fares of all the airlines from all the origins, with the options of req.
+//
+// If req.Destination is empty, all destinations are searched.
+//
+// Each source reports its own error in its Stat, and the returned error joins them,
+// but the fares of the healthy sources are returned.
+// If strict, the first failing source cancels the others, and its error is returned.
+func searchFares(ctx context.Context, airlines map[string]airline.Airline, origins []string, req airline.SearchRequest, strict bool) ([]airline.Fare, map[string]Stat, error) {
+	return streamFares(ctx, airlines, origins, req, strict, nil)
+}
+
+// streamFares is searchFares, calling each (if not nil) with the result of each search as it arrives.
+func streamFares(ctx context.Context, airlines map[string]airline.Airline, origins []string, req airline.SearchRequest, strict bool, each func(airline.Result)) ([]airline.Fare, map[string]Stat, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stats := make(map[string]Stat, len(airlines))
+	errs := make(map[string][]error, len(airlines))
+	var fares []airline.Fare
+	var firstErr error
+	for res := range airline.Stream(ctx, airlines, origins, req) {
+		if each != nil {
+			each(res)
+		}
+		if res.Partial {
+			continue
+		}
+		st := stats[res.Source]
+		st.Dur = max(st.Dur, res.Dur)
+		st.N += len(res.Fares)
+		if res.Err != nil {
+			errs[res.Source] = append(errs[res.Source], res.Err)
+			st.Err = errors.Join(errs[res.Source]...).Error()
+			if strict && firstErr == nil {
+				firstErr = res.Err
+				cancel()
+			}
+		}
+		stats[res.Source] = st
+		fares = append(fares, res.Fares...)
+	}
+	if strict {
+		return fares, stats, firstErr
+	}
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var all []error
+	for _, name := range names {
+		all = append(all, errs[name]...)
+	}
+	return fares, stats, errors.Join(all...)
+}
+
+// logStats logs the status of each source.
+func logStats(logger *slog.Logger, stats map[string]Stat) {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		st := stats[name]
+		if st.Err != "" {
+			logger.Warn("source failed", "source", name, "fares", st.N, "dur", st.Dur, "error", st.Err)
+		} else {
+			logger.Info("source", "source", name, "fares", st.N, "dur", st.Dur)
+		}
+	}
+}
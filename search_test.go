@@ -0,0 +1,66 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+type failingAirline struct{ err error }
+
+func (fa failingAirline) Destinations(ctx context.Context, origin string) ([]string, error) {
+	return nil, fa.err
+}
+func (fa failingAirline) Fares(ctx context.Context, origin, destination string, departure time.Time, currency string) ([]airline.Fare, error) {
+	return nil, fa.err
+}
+
+// slowAirline returns only when the context is canceled.
+type slowAirline struct{ failingAirline }
+
+func (sa slowAirline) Fares(ctx context.Context, origin, destination string, departure time.Time, currency string) ([]airline.Fare, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestSearchFaresPartial(t *testing.T) {
+	errForbidden := errors.New("403 Forbidden")
+	airlines := map[string]airline.Airline{
+		"fake":    new(fakeAirline),
+		"failing": failingAirline{err: errForbidden},
+	}
+	ctx := context.Background()
+	dr := airline.Day(time.Date(2024, 10, 20, 0, 0, 0, 0, time.Local))
+	req := airline.SearchRequest{Destination: "LIS", Departure: dr, Currency: "EUR"}
+	fares, stats, err := searchFares(ctx, airlines, []string{"BUD"}, req, false)
+	if !errors.Is(err, errForbidden) {
+		t.Errorf("got %+v, wanted %v", err, errForbidden)
+	}
+	if len(fares) != 1 || fares[0].Source != "fake" {
+		t.Errorf("got %+v, wanted the fare of the healthy source", fares)
+	}
+	if st := stats["failing"]; !strings.Contains(st.Err, "403") {
+		t.Errorf("failing: got %+v", st)
+	}
+	if st := stats["fake"]; st.Err != "" || st.N != 1 {
+		t.Errorf("fake: got %+v", st)
+	}
+
+	airlines["slow"] = slowAirline{}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if _, _, err = searchFares(ctx, airlines, []string{"BUD"}, req, true); !errors.Is(err, errForbidden) {
+		t.Errorf("strict: got %+v, wanted %v", err, errForbidden)
+	}
+	if ctx.Err() != nil {
+		t.Error("strict: the slow source was not canceled")
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/tgulacsi/fly/airline"
+	"github.com/tgulacsi/fly/iata"
+)
+
+func newServeCmd(open openFunc) *ffcli.Command {
+	FS := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flagAddr := FS.String("addr", "localhost:8080", "address to listen on")
+	flagTTL := FS.Duration("ttl", 30*time.Minute, "keep the search results this long")
+	flagMaxCached := FS.Int("max-cached", 256, "keep at most this many search results")
+	return &ffcli.Command{Name: "serve", FlagSet: FS,
+		ShortHelp: "serve the fares and destinations over HTTP",
+		Exec: func(ctx context.Context, args []string) error {
+			airlines, err := open(ctx)
+			if err != nil {
+				return err
+			}
+			destinations, err := airline.DefaultRegistry.Get(ctx, "ryanair")
+			if err != nil {
+				return err
+			}
+			srv := &http.Server{
+				Addr:        *flagAddr,
+				Handler:     newServer(airlines, destinations, *flagTTL, *flagMaxCached),
+				BaseContext: func(_ net.Listener) context.Context { return ctx },
+			}
+			go func() {
+				<-ctx.Done()
+				shutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				srv.Shutdown(shutCtx)
+			}()
+			airline.CtxLogger(ctx).Info("serving", "addr", *flagAddr)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+type server struct {
+	airlines     map[string]airline.Airline
+	destinations airline.Airline
+	mux          *http.ServeMux
+	cache        map[string]cachedFares
+	group        singleflight.Group
+	ttl          time.Duration
+	maxCached    int
+	mu           sync.Mutex
+}
+
+type cachedFares struct {
+	Created time.Time
+	Fares   []airline.Fare
+	Stats   map[string]Stat
+	Err     string
+}
+
+// faresQuery is the parsed query of the fares endpoints.
+type faresQuery struct {
+	Origins     airline.Origins
+	DateRange   airline.DateRange
+	Date        string
+	Origin      string
+	Destination string
+	Currency    string
+	// Under is the ceiling of the effective price, in Currency.
+	Under airline.Money
+}
+
+func newServer(airlines map[string]airline.Airline, destinations airline.Airline, ttl time.Duration, maxCached int) *server {
+	s := &server{
+		airlines: airlines, destinations: destinations, ttl: ttl, maxCached: max(maxCached, 1),
+		cache: make(map[string]cachedFares),
+		mux:   http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /api/fares", s.apiFares)
+	s.mux.HandleFunc("GET /api/destinations", s.apiDestinations)
+	s.mux.HandleFunc("GET /{$}", s.page)
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) { s.mux.ServeHTTP(w, r) }
+
+func parseFaresQuery(r *http.Request) (faresQuery, error) {
+	q := r.URL.Query()
+	fq := faresQuery{
+		Origin: q.Get("origin"), Date: q.Get("date"),
+		Destination: strings.ToUpper(q.Get("destination")),
+		Currency:    strings.ToUpper(q.Get("currency")),
+	}
+	if fq.Origin == "" {
+		fq.Origin = "BUD"
+	}
+	if fq.Currency == "" {
+		fq.Currency = "EUR"
+	}
+	var err error
+	if fq.Origins, err = airline.ParseOrigins(fq.Origin); err != nil {
+		return fq, err
+	}
+	if fq.DateRange, err = parseDateRange(fq.Date); err != nil {
+		return fq, err
+	}
+	if flex := q.Get("flex"); flex != "" {
+		n, err := strconv.Atoi(flex)
+		if err != nil {
+			return fq, err
+		}
+		fq.DateRange.From, fq.DateRange.To = fq.DateRange.From.AddDate(0, 0, -n), fq.DateRange.To.AddDate(0, 0, n)
+	}
+	if under := q.Get("under"); under != "" {
+		if fq.Under, err = airline.ParseMoney(under, fq.Currency); err != nil {
+			return fq, err
+		}
+	}
+	return fq, nil
+}
+
+// fares returns the (cached) fares of the query, cheapest first.
+func (s *server) fares(ctx context.Context, fq faresQuery) (cachedFares, error) {
+	key := strings.Join([]string{
+		strings.Join(fq.Origins.Codes(), ","), fq.Destination,
+		fq.DateRange.From.Format("2006-01-02"), fq.DateRange.To.Format("2006-01-02"),
+		fq.Currency,
+	}, "|")
+	s.mu.Lock()
+	cf, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Since(cf.Created) < s.ttl {
+		return cf, nil
+	}
+	v, err, _ := s.group.Do(key, func() (any, error) {
+		// do not let one canceled request cancel the others waiting for the same search
+		ctx := context.WithoutCancel(ctx)
+		fares, stats, err := searchFares(ctx, s.airlines, fq.Origins.Codes(), airline.SearchRequest{
+			Destination: fq.Destination, Departure: fq.DateRange, Currency: fq.Currency,
+		}, false)
+		cf := cachedFares{Created: time.Now(), Fares: fares, Stats: stats}
+		if err != nil {
+			cf.Err = err.Error()
+			airline.CtxLogger(ctx).Warn("search", "key", key, "error", err)
+		}
+		slices.SortStableFunc(cf.Fares, func(a, b airline.Fare) int {
+			return cmp.Or(
+				fq.Origins.Effective(a).Cmp(fq.Origins.Effective(b)),
+				cmp.Compare(a.Day, b.Day),
+				cmp.Compare(a.Destination, b.Destination),
+			)
+		})
+		cf.Fares = airline.MergeFares(cf.Fares)
+		if len(cf.Fares) != 0 || err == nil {
+			s.store(key, cf)
+		}
+		return cf, nil
+	})
+	if err != nil {
+		return cachedFares{}, err
+	}
+	return v.(cachedFares), nil
+}
+
+// store the search result in the cache, after removing the expired ones,
+// and the oldest ones over maxCached.
+func (s *server) store(key string, cf cachedFares) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, c := range s.cache {
+		if now.Sub(c.Created) >= s.ttl {
+			delete(s.cache, k)
+		}
+	}
+	for len(s.cache) >= s.maxCached {
+		var oldest string
+		for k, c := range s.cache {
+			if oldest == "" || c.Created.Before(s.cache[oldest].Created) {
+				oldest = k
+			}
+		}
+		delete(s.cache, oldest)
+	}
+	s.cache[key] = cf
+}
+
+// fareRow is a fare enriched with the destination airport, and the effective price.
+type fareRow struct {
+	airline.Fare
+	DestinationAirport iata.Airport  `json:"destinationAirport"`
+	Effective          airline.Money `json:"effective"`
+}
+
+func (fq faresQuery) rows(ctx context.Context, fares []airline.Fare) []fareRow {
+	rows := make([]fareRow, 0, len(fares))
+	for _, f := range fares {
+		eff := fq.Origins.Effective(f)
+		if over, err := eff.Over(ctx, fq.Under); err != nil {
+			airline.CtxLogger(ctx).Warn("compare to under", "destination", f.Destination, "day", f.Day, "error", err)
+		} else if over {
+			continue
+		}
+		rows = append(rows, fareRow{Fare: f, DestinationAirport: iata.Get(f.Destination), Effective: eff})
+	}
+	return rows
+}
+
+func (s *server) apiFares(w http.ResponseWriter, r *http.Request) {
+	fq, err := parseFaresQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cf, err := s.fares(r.Context(), fq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, struct {
+		Created time.Time       `json:"created"`
+		Error   string          `json:"error,omitempty"`
+		Stats   map[string]Stat `json:"stats"`
+		Fares   []fareRow       `json:"fares"`
+	}{cf.Created, cf.Err, cf.Stats, fq.rows(r.Context(), cf.Fares)})
+}
+
+func (s *server) apiDestinations(w http.ResponseWriter, r *http.Request) {
+	origin := strings.ToUpper(r.URL.Query().Get("origin"))
+	if origin == "" {
+		http.Error(w, "origin is required", http.StatusBadRequest)
+		return
+	}
+	destinations, err := s.destinations.Destinations(r.Context(), origin)
+	if err != nil && len(destinations) == 0 {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, struct {
+		Destinations []string `json:"destinations"`
+	}{destinations})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *server) page(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Query   faresQuery
+		Created time.Time
+		Error   string
+		Fares   []fareRow
+	}{Query: faresQuery{Origin: "BUD", Currency: "EUR"}}
+	if r.URL.Query().Get("date") != "" {
+		fq, err := parseFaresQuery(r)
+		data.Query = fq
+		if err != nil {
+			data.Error = err.Error()
+		} else if cf, err := s.fares(r.Context(), fq); err != nil {
+			data.Error = err.Error()
+		} else {
+			data.Created, data.Error, data.Fares = cf.Created, cf.Err, fq.rows(r.Context(), cf.Fares)
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pageTemplate.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>fly</title>
+<style>
+body { font-family: sans-serif; }
+th { cursor: pointer; text-align: left; }
+td.num { text-align: right; }
+</style></head>
+<body>
+<form method="get">
+<label>Origin <input name="origin" value="{{.Query.Origin}}"></label>
+<label>Date <input name="date" value="{{.Query.Date}}" placeholder="2024-10-01..2024-10-31"></label>
+<label>Destination <input name="destination" value="{{.Query.Destination}}" size="4"></label>
+<label>Currency <input name="currency" value="{{.Query.Currency}}" size="4"></label>
+<label>Under <input name="under" value="{{if not .Query.Under.IsZero}}{{.Query.Under.Amount}}{{end}}" size="6"></label>
+<button type="submit">Search</button>
+</form>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .Fares}}
+<p>{{len .Fares}} fares, searched at {{.Created.Format "2006-01-02 15:04"}}</p>
+<table id="fares"><thead><tr>
+<th data-type="num">Price</th><th data-type="num">Member</th><th data-type="num">Regular</th><th data-type="num">Was</th><th>Day</th><th>Departure</th><th>Origin</th><th>Destination</th><th>Country</th><th>City</th><th>Airline</th><th>Source</th>
+</tr></thead><tbody>
+{{range .Fares}}<tr><td class="num">{{printf "%.2f" .Effective}} {{.Effective.Currency}}</td><td class="num">{{if not .MemberPrice.IsZero}}{{printf "%.2f" .MemberPrice}}{{end}}</td><td class="num">{{if not .RegularPrice.IsZero}}{{printf "%.2f" .RegularPrice}}{{end}}</td><td class="num">{{if not .OriginalPrice.IsZero}}{{printf "%.2f" .OriginalPrice}}{{end}}</td><td>{{.Day}}</td><td>{{if not .Departure.IsZero}}{{.Departure.Format "15:04"}}{{end}}</td><td>{{.Origin}}</td><td>{{.Destination}}</td><td>{{.DestinationAirport.Country}}</td><td>{{.DestinationAirport.Municipality}}</td><td>{{.Airline}}</td><td>{{.Source}}</td></tr>
+{{end}}</tbody></table>
+<script>
+document.querySelectorAll("#fares th").forEach(function(th, col) {
+  th.addEventListener("click", function() {
+    var tbody = th.closest("table").tBodies[0];
+    var num = th.dataset.type === "num";
+    var asc = th.dataset.order !== "asc";
+    th.dataset.order = asc ? "asc" : "desc";
+    var rows = Array.from(tbody.rows);
+    rows.sort(function(a, b) {
+      var x = a.cells[col].textContent, y = b.cells[col].textContent;
+      var c = num ? parseFloat(x) - parseFloat(y) : x.localeCompare(y);
+      return asc ? c : -c;
+    });
+    rows.forEach(function(r) { tbody.appendChild(r); });
+  });
+});
+</script>
+{{end}}
+</body></html>
+`))
@@ -0,0 +1,93 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+type fakeAirline struct{ calls atomic.Int32 }
+
+func (fa *fakeAirline) Destinations(ctx context.Context, origin string) ([]string, error) {
+	return []string{"STN", "LIS"}, nil
+}
+func (fa *fakeAirline) Fares(ctx context.Context, origin, destination string, departure time.Time, currency string) ([]airline.Fare, error) {
+	fa.calls.Add(1)
+	price := 30.0
+	if destination == "LIS" {
+		price = 20
+	}
+	return []airline.Fare{{
+		Airline: "Fake", Source: "fake", Origin: origin, Destination: destination,
+		Day: departure.Format("2006-01-02"), Departure: departure.Add(6 * time.Hour),
+		Price: airline.NewMoney(price, currency),
+	}}, nil
+}
+
+func TestServe(t *testing.T) {
+	fa := new(fakeAirline)
+	srv := httptest.NewServer(newServer(map[string]airline.Airline{"fake": fa}, fa, time.Minute, 16))
+	defer srv.Close()
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(srv.URL + "/api/fares?origin=BUD&date=2024-10-20")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res struct {
+			Fares []struct {
+				Destination string        `json:"destination"`
+				Price       airline.Money `json:"price"`
+			} `json:"fares"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Fares) != 2 || res.Fares[0].Destination != "LIS" {
+			t.Errorf("got %+v", res.Fares)
+		}
+	}
+	if n := fa.calls.Load(); n != 2 {
+		t.Errorf("got %d calls, wanted 2 (one search, cached)", n)
+	}
+
+	resp, err := http.Get(srv.URL + "/?origin=BUD&date=2024-10-20&under=25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	_, _ = io.Copy(&buf, resp.Body)
+	resp.Body.Close()
+	if s := buf.String(); !strings.Contains(s, "<td>LIS</td>") || strings.Contains(s, "<td>STN</td>") {
+		t.Errorf("got %s", s)
+	}
+}
+
+func TestServeCacheLimit(t *testing.T) {
+	s := newServer(nil, nil, time.Minute, 2)
+	now := time.Now()
+	s.store("expired", cachedFares{Created: now.Add(-time.Hour)})
+	s.store("old", cachedFares{Created: now.Add(-2 * time.Second)})
+	s.store("new", cachedFares{Created: now.Add(-time.Second)})
+	if _, ok := s.cache["expired"]; ok || len(s.cache) != 2 {
+		t.Errorf("got %d entries, wanted the expired one removed", len(s.cache))
+	}
+	s.store("newest", cachedFares{Created: now})
+	if _, ok := s.cache["old"]; ok || len(s.cache) != 2 {
+		t.Errorf("got %v, wanted the oldest one removed", s.cache)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"strings"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+// openFunc opens the selected sources.
+type openFunc func(context.Context) (map[string]airline.Airline, error)
+
+// sourceFlags select the sources from the airline.DefaultRegistry.
+type sourceFlags struct {
+	include, exclude string
+}
+
+func (sf *sourceFlags) register(FS *flag.FlagSet) {
+	names := strings.Join(airline.DefaultRegistry.Names(), ",")
+	FS.StringVar(&sf.include, "sources", "", "use only these sources, comma separated (of "+names+")")
+	FS.StringVar(&sf.exclude, "exclude", "", "do not use these sources, comma separated")
+}
+
+// open initializes the selected sources.
+//
+// The sources failing to initialize are logged and left out;
+// it is an error only if no source remains.
+func (sf *sourceFlags) open(ctx context.Context) (map[string]airline.Airline, error) {
+	names, err := airline.DefaultRegistry.Select(splitList(sf.include), splitList(sf.exclude))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no source selected")
+	}
+	airlines, err := airline.DefaultRegistry.Open(ctx, names)
+	if err != nil {
+		if len(airlines) == 0 {
+			return nil, err
+		}
+		airline.CtxLogger(ctx).Warn("sources disabled", "error", err)
+	}
+	return airlines, nil
+}
+
+func splitList(s string) []string {
+	var ss []string
+	for _, x := range strings.Split(s, ",") {
+		if x = strings.TrimSpace(x); x != "" {
+			ss = append(ss, x)
+		}
+	}
+	return ss
+}
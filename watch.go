@@ -0,0 +1,125 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+
+	"github.com/tgulacsi/fly/airline"
+	"github.com/tgulacsi/fly/history"
+	"github.com/tgulacsi/fly/watch"
+)
+
+func newWatchCmd(open openFunc) *ffcli.Command {
+	FS := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flagConfig := FS.String("config", "", "JSON config file with interval, searches and sinks")
+	flagOrigin := FS.String("origin", "BUD", "origins, comma separated")
+	flagCurrency := FS.String("currency", "EUR", "currency")
+	flagUnder := FS.Float64("under", 50, "alert on fares appearing under this price")
+	flagDrop := FS.Float64("drop", 0, "alert on fares dropping by this percentage")
+	flagInterval := FS.Duration("interval", time.Hour, "check interval")
+	flagWebhook := FS.String("webhook", "", "POST the alerts as JSON to this URL")
+	flagOnce := FS.Bool("once", false, "check only once")
+	flagHistory := FS.String("history", defaultHistoryPath(), "history database to compare with and record into (empty to disable)")
+	return &ffcli.Command{Name: "watch", FlagSet: FS,
+		ShortUsage: "watch [flags] [DATE[..DATE] [DESTINATION...]]",
+		ShortHelp:  "re-run searches periodically and alert on cheap fares",
+		Exec: func(ctx context.Context, args []string) error {
+			var cfg watch.Config
+			if *flagConfig != "" {
+				var err error
+				if cfg, err = watch.LoadConfig(*flagConfig); err != nil {
+					return err
+				}
+			} else {
+				if len(args) < 1 {
+					return fmt.Errorf("need config or date")
+				}
+				dr, err := parseDateRange(args[0])
+				if err != nil {
+					return err
+				}
+				cfg.Interval = watch.Duration(*flagInterval)
+				cfg.Searches = []watch.Search{{
+					Origin: *flagOrigin, Destinations: args[1:],
+					From: dr.From.Format("2006-01-02"), To: dr.To.Format("2006-01-02"),
+					Currency: *flagCurrency, Under: airline.NewMoney(*flagUnder, *flagCurrency), Drop: *flagDrop,
+				}}
+				cfg.Sinks = []watch.SinkConfig{{Type: "stdout"}}
+				if *flagWebhook != "" {
+					cfg.Sinks = append(cfg.Sinks, watch.SinkConfig{Type: "webhook", URL: *flagWebhook})
+				}
+			}
+			airlines, err := open(ctx)
+			if err != nil {
+				return err
+			}
+			w := watch.Watcher{Fetch: func(ctx context.Context, s watch.Search) ([]airline.Fare, error) {
+				return fetchSearch(ctx, airlines, s)
+			}}
+			for _, sc := range cfg.Sinks {
+				sink, err := watch.NewSink(sc)
+				if err != nil {
+					return err
+				}
+				w.Sinks = append(w.Sinks, sink)
+			}
+			if *flagHistory != "" {
+				db, err := history.Open(*flagHistory)
+				if err != nil {
+					return err
+				}
+				defer db.Close()
+				w.History = db
+			}
+			if *flagOnce {
+				for _, s := range cfg.Searches {
+					alerts, err := w.Check(ctx, s)
+					if notifyErr := w.Notify(ctx, alerts); notifyErr != nil {
+						return notifyErr
+					}
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			return w.Run(ctx, time.Duration(cfg.Interval), cfg.Searches)
+		},
+	}
+}
+
+func fetchSearch(ctx context.Context, airlines map[string]airline.Airline, s watch.Search) ([]airline.Fare, error) {
+	dr, err := s.DateRange()
+	if err != nil {
+		return nil, err
+	}
+	origins, err := airline.ParseOrigins(s.Origin)
+	if err != nil {
+		return nil, err
+	}
+	currency := cmp.Or(s.Currency, watch.DefaultCurrency)
+	destinations := s.Destinations
+	if len(destinations) == 0 {
+		destinations = []string{""}
+	}
+	var fares []airline.Fare
+	var errs []error
+	for _, dest := range destinations {
+		local, _, err := searchFares(ctx, airlines, origins.Codes(), airline.SearchRequest{
+			Destination: dest, Departure: dr, Currency: currency,
+		}, false)
+		fares = append(fares, local...)
+		errs = append(errs, err)
+	}
+	return fares, errors.Join(errs...)
+}
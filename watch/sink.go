@@ -0,0 +1,120 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+// Sink is notified about the alerts.
+type Sink interface {
+	Notify(ctx context.Context, alerts []Alert) error
+}
+
+// SinkConfig configures a sink.
+type SinkConfig struct {
+	// Type is one of stdout, webhook, smtp.
+	Type string `json:"type"`
+	// URL of the webhook.
+	URL string `json:"url,omitempty"`
+	// Addr (host:port) of the SMTP server.
+	Addr     string   `json:"addr,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+}
+
+// NewSink returns the configured sink.
+func NewSink(cfg SinkConfig) (Sink, error) {
+	switch cfg.Type {
+	case "", "stdout":
+		return WriterSink{W: os.Stdout}, nil
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook needs url")
+		}
+		return WebhookSink{URL: cfg.URL, Client: airline.NewClient(nil, false)}, nil
+	case "smtp":
+		if cfg.Addr == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("smtp needs addr and to")
+		}
+		s := SMTPSink{Addr: cfg.Addr, From: cfg.From, To: cfg.To}
+		if cfg.Username != "" {
+			host, _, _ := strings.Cut(cfg.Addr, ":")
+			s.Auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+}
+
+// WriterSink writes a line for each alert.
+type WriterSink struct{ W io.Writer }
+
+func (s WriterSink) Notify(ctx context.Context, alerts []Alert) error {
+	var buf bytes.Buffer
+	for _, a := range alerts {
+		buf.WriteString(a.String())
+		buf.WriteByte('\n')
+	}
+	_, err := s.W.Write(buf.Bytes())
+	return err
+}
+
+// WebhookSink POSTs the alerts as JSON: {"alerts":[...]}.
+type WebhookSink struct {
+	Client airline.HTTPClient
+	URL    string
+}
+
+func (s WebhookSink) Notify(ctx context.Context, alerts []Alert) error {
+	b, err := json.Marshal(struct {
+		Alerts []Alert `json:"alerts"`
+	}{alerts})
+	if err != nil {
+		return err
+	}
+	_, _, err = s.Client.Post(
+		airline.WithPrepare(ctx, func(r *http.Request) {
+			r.Header.Set("Content-Type", "application/json")
+		}),
+		s.URL, bytes.NewReader(b))
+	return err
+}
+
+// SMTPSink sends the alerts in a mail.
+type SMTPSink struct {
+	Auth smtp.Auth
+	Addr string
+	From string
+	To   []string
+}
+
+func (s SMTPSink) Notify(ctx context.Context, alerts []Alert) error {
+	from := s.From
+	if from == "" {
+		from = "fly@localhost"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\nSubject: fly: %d cheap fares\r\nDate: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n",
+		from, strings.Join(s.To, ", "), len(alerts), time.Now().Format(time.RFC1123Z))
+	for _, a := range alerts {
+		buf.WriteString(a.String())
+		buf.WriteString("\r\n")
+	}
+	return smtp.SendMail(s.Addr, s.Auth, from, s.To, buf.Bytes())
+}
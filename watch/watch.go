@@ -0,0 +1,281 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package watch re-runs searches periodically, and alerts on cheap fares.
+package watch
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+	"github.com/tgulacsi/fly/history"
+)
+
+// Search is a watched search.
+type Search struct {
+	Name         string   `json:"name"`
+	Origin       string   `json:"origin"`
+	Destinations []string `json:"destinations"`
+	From         string   `json:"from"`
+	To           string   `json:"to"`
+	Currency     string   `json:"currency"`
+	// Under is the price ceiling: only fares under it are alerted.
+	// A bare number (without currency) is in Currency.
+	Under airline.Money `json:"under"`
+	// Drop is the percentage of price drop (compared to the last observation) to alert on.
+	Drop float64 `json:"drop"`
+}
+
+func (s Search) String() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Origin + "-" + strings.Join(s.Destinations, ",") + " " + s.From + ".." + s.To
+}
+
+// DefaultCurrency is the currency of the searches without Currency.
+const DefaultCurrency = "EUR"
+
+// Ceiling returns Under, in Currency if it has no currency of its own.
+func (s Search) Ceiling() airline.Money {
+	under := s.Under
+	if under.Currency == "" {
+		under.Currency = cmp.Or(s.Currency, DefaultCurrency)
+	}
+	return under
+}
+
+// DateRange returns the date window of the search.
+func (s Search) DateRange() (airline.DateRange, error) {
+	var dr airline.DateRange
+	var err error
+	if dr.From, err = time.ParseInLocation("2006-01-02", s.From, time.Local); err != nil {
+		return dr, fmt.Errorf("%s: parse from %q: %w", s, s.From, err)
+	}
+	if s.To == "" {
+		dr.To = dr.From
+	} else if dr.To, err = time.ParseInLocation("2006-01-02", s.To, time.Local); err != nil {
+		return dr, fmt.Errorf("%s: parse to %q: %w", s, s.To, err)
+	}
+	return dr, nil
+}
+
+// Reason of an alert.
+type Reason string
+
+const (
+	// ReasonUnder is for a fare which appeared under the price ceiling.
+	ReasonUnder = Reason("under")
+	// ReasonDrop is for a fare whose price dropped.
+	ReasonDrop = Reason("drop")
+)
+
+// Alert is a notification about a fare.
+type Alert struct {
+	Search   string        `json:"search"`
+	Reason   Reason        `json:"reason"`
+	Fare     airline.Fare  `json:"fare"`
+	Previous airline.Money `json:"previous,omitzero"`
+}
+
+func (a Alert) String() string {
+	f := a.Fare
+	s := fmt.Sprintf("%s: %s %s %s %s-%s %s[%s]",
+		a.Search, a.Reason, f.Price, f.Day, f.Origin, f.Destination, f.Airline, f.Source)
+	if !a.Previous.IsZero() {
+		s += fmt.Sprintf(" (was %s)", a.Previous.Amount())
+	}
+	return s
+}
+
+// FetchFunc returns the current fares for the search.
+type FetchFunc func(context.Context, Search) ([]airline.Fare, error)
+
+// Watcher checks the searches, and notifies the sinks about the alerts.
+type Watcher struct {
+	Fetch FetchFunc
+	Sinks []Sink
+	// History is optional: the last observations are looked up
+	// and the new observations are recorded there.
+	History *history.DB
+
+	mu   sync.Mutex
+	last map[fareKey]airline.Fare
+}
+
+type fareKey struct {
+	Source, Origin, Destination, Day string
+	Departure                        time.Time
+}
+
+func keyOf(f airline.Fare) fareKey {
+	return fareKey{
+		Source: f.Source, Origin: f.Origin, Destination: f.Destination,
+		Day: f.Day, Departure: f.Departure.UTC(),
+	}
+}
+
+// Check the search: fetch the fares and compare them to the last observations.
+func (w *Watcher) Check(ctx context.Context, s Search) ([]Alert, error) {
+	fares, err := w.Fetch(ctx, s)
+	if len(fares) == 0 {
+		return nil, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.last == nil {
+		w.last = make(map[fareKey]airline.Fare)
+	}
+	under := s.Ceiling()
+	var alerts []Alert
+	// the fares which cannot be compared to the ceiling are not alerted on, with the error
+	var convErr error
+	for _, f := range fares {
+		k := keyOf(f)
+		prev, ok := w.last[k]
+		if !ok {
+			prev, ok = w.lastFromHistory(f)
+		}
+		w.last[k] = f
+		if ok && prev.Price.Currency != f.Price.Currency {
+			ok = false
+		}
+		over, err := f.Price.Over(ctx, under)
+		if err != nil {
+			if convErr == nil {
+				convErr = err
+			}
+			continue
+		}
+		if over {
+			continue
+		}
+		var wasOver bool
+		if ok {
+			wasOver, _ = prev.Price.Over(ctx, under) // in the currency of f
+		}
+		switch {
+		case !under.IsZero() && (!ok || wasOver):
+			a := Alert{Search: s.String(), Reason: ReasonUnder, Fare: f}
+			if ok {
+				a.Previous = prev.Price
+			}
+			alerts = append(alerts, a)
+		case ok && s.Drop > 0 && f.Price.Cmp(prev.Price.Percent(100-s.Drop)) <= 0:
+			alerts = append(alerts, Alert{Search: s.String(), Reason: ReasonDrop, Fare: f, Previous: prev.Price})
+		}
+	}
+	err = errors.Join(err, convErr)
+	if w.History != nil {
+		q := history.Query{Origin: s.Origin, Destination: strings.Join(s.Destinations, ","), From: s.From, To: s.To, Currency: s.Currency}
+		if histErr := w.History.Record(time.Now(), q, fares); histErr != nil {
+			err = errors.Join(err, histErr)
+		}
+	}
+	return alerts, err
+}
+
+func (w *Watcher) lastFromHistory(f airline.Fare) (airline.Fare, bool) {
+	if w.History == nil {
+		return airline.Fare{}, false
+	}
+	records, err := w.History.Route(f.Origin, f.Destination, f.Day)
+	if err != nil {
+		return airline.Fare{}, false
+	}
+	k := keyOf(f)
+	for i := len(records) - 1; i >= 0; i-- {
+		if keyOf(records[i].Fare) == k {
+			return records[i].Fare, true
+		}
+	}
+	return airline.Fare{}, false
+}
+
+// Notify all the sinks about the alerts.
+func (w *Watcher) Notify(ctx context.Context, alerts []Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, s := range w.Sinks {
+		if err := s.Notify(ctx, alerts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run checks the searches every interval, until the context is canceled.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration, searches []Search) error {
+	logger := airline.CtxLogger(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, s := range searches {
+			alerts, err := w.Check(ctx, s)
+			if err != nil {
+				logger.Warn("check", "search", s.String(), "error", err)
+			}
+			if err = w.Notify(ctx, alerts); err != nil {
+				logger.Error("notify", "search", s.String(), "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Config of the watcher.
+type Config struct {
+	Interval Duration     `json:"interval"`
+	Searches []Search     `json:"searches"`
+	Sinks    []SinkConfig `json:"sinks"`
+}
+
+// Duration is a time.Duration which is (un)marshaled as a string ("1h30m").
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) { return json.Marshal(time.Duration(d).String()) }
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return err
+}
+
+// LoadConfig reads the JSON config file.
+func LoadConfig(fn string) (Config, error) {
+	var cfg Config
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return cfg, err
+	}
+	if err = json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %q: %w", fn, err)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = Duration(time.Hour)
+	}
+	for _, s := range cfg.Searches {
+		if _, err := s.DateRange(); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package watch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+func TestCheck(t *testing.T) {
+	dep := time.Date(2024, 10, 20, 6, 0, 0, 0, time.UTC)
+	fare := func(dest string, price float64) airline.Fare {
+		return airline.Fare{
+			Source: "ryanair", Origin: "BUD", Destination: dest,
+			Day: "2024-10-20", Departure: dep, Price: airline.NewMoney(price, "EUR"),
+		}
+	}
+	var current []airline.Fare
+	w := Watcher{Fetch: func(context.Context, Search) ([]airline.Fare, error) { return current, nil }}
+	s := Search{Name: "test", Under: airline.NewMoney(50, ""), Drop: 20}
+	ctx := context.Background()
+
+	current = []airline.Fare{fare("STN", 40), fare("LIS", 60), fare("BCN", 45)}
+	alerts, err := w.Check(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 || alerts[0].Reason != ReasonUnder || alerts[1].Reason != ReasonUnder {
+		t.Fatalf("first check: got %v", alerts)
+	}
+
+	// STN: 40 -> 30 (-25%) drop; LIS: 60 -> 49 appears under; BCN: 45 -> 40 (-11%) nothing
+	current = []airline.Fare{fare("STN", 30), fare("LIS", 49), fare("BCN", 40)}
+	if alerts, err = w.Check(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("second check: got %v", alerts)
+	}
+	for _, a := range alerts {
+		switch a.Fare.Destination {
+		case "STN":
+			if a.Reason != ReasonDrop || a.Previous != airline.NewMoney(40, "EUR") {
+				t.Errorf("STN: got %v", a)
+			}
+		case "LIS":
+			if a.Reason != ReasonUnder || a.Previous != airline.NewMoney(60, "EUR") {
+				t.Errorf("LIS: got %v", a)
+			}
+		default:
+			t.Errorf("unexpected %v", a)
+		}
+	}
+}
+
+func TestCheckCurrency(t *testing.T) {
+	var s Search
+	if err := json.Unmarshal([]byte(`{"currency":"HUF","under":20000}`), &s); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Ceiling(); got != airline.NewMoney(20000, "HUF") {
+		t.Errorf("got ceiling %v, wanted 20000 HUF", got)
+	}
+	fares := []airline.Fare{
+		{Source: "ryanair", Origin: "BUD", Destination: "STN", Day: "2024-10-20", Price: airline.NewMoney(40, "EUR")},
+		{Source: "ryanair", Origin: "BUD", Destination: "LIS", Day: "2024-10-20", Price: airline.NewMoney(60, "EUR")},
+	}
+	w := Watcher{Fetch: func(context.Context, Search) ([]airline.Fare, error) { return fares, nil }}
+	// 16000 and 24000 HUF
+	ctx := airline.WithConverter(context.Background(), airline.NewRates("HUF", map[string]float64{"EUR": 400}))
+	alerts, err := w.Check(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Fare.Destination != "STN" {
+		t.Errorf("got %v, wanted STN only", alerts)
+	}
+	// not comparable without a converter
+	w = Watcher{Fetch: w.Fetch}
+	if alerts, err = w.Check(context.Background(), s); err == nil || len(alerts) != 0 {
+		t.Errorf("got %v (%+v), wanted an error", alerts, err)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	got := make(chan []Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Alerts []Alert `json:"alerts"`
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("got content-type %q", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		got <- req.Alerts
+	}))
+	defer srv.Close()
+	sink := WebhookSink{URL: srv.URL, Client: airline.NewClient(srv.Client(), false)}
+	alerts := []Alert{{Search: "test", Reason: ReasonUnder, Fare: airline.Fare{Destination: "STN", Price: airline.NewMoney(10, "EUR")}}}
+	if err := sink.Notify(context.Background(), alerts); err != nil {
+		t.Fatal(err)
+	}
+	if a := <-got; len(a) != 1 || a[0].Fare.Destination != "STN" {
+		t.Errorf("got %v", a)
+	}
+}
+
+func TestSMTPSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		for inData := false; ; {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					got <- data.String()
+					reply("250 OK")
+				} else {
+					data.WriteString(line)
+				}
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	sink := SMTPSink{Addr: ln.Addr().String(), From: "fly@example.com", To: []string{"me@example.com"}}
+	alerts := []Alert{{Search: "test", Reason: ReasonDrop, Previous: airline.NewMoney(40, "EUR"), Fare: airline.Fare{Destination: "STN", Price: airline.NewMoney(30, "EUR")}}}
+	if err := sink.Notify(context.Background(), alerts); err != nil {
+		t.Fatal(err)
+	}
+	if mail := <-got; !strings.Contains(mail, "To: me@example.com") || !strings.Contains(mail, "STN") || !strings.Contains(mail, "was 40.00") {
+		t.Errorf("got %q", mail)
+	}
+}
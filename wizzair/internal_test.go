@@ -0,0 +1,45 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package wizzair
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheapMonths(t *testing.T) {
+	now := time.Date(2024, 10, 15, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		last time.Time
+		want int
+	}{
+		{now.AddDate(0, 1, 0), 6},
+		{now.AddDate(0, 6, 0), 6},
+		{now.AddDate(0, 7, 3), 8},
+	} {
+		if got := cheapMonths(now, tc.last); got != tc.want {
+			t.Errorf("%s: got %d, wanted %d", tc.last.Format("2006-01-02"), got, tc.want)
+		}
+	}
+}
+
+func TestParseBuildNumber(t *testing.T) {
+	for in, want := range map[string]string{
+		"SSR https://be.wizzair.com/25.3.0":       "https://be.wizzair.com/25.3.0",
+		"SSR https://api.wizzair.com/25.3.0/":     "https://api.wizzair.com/25.3.0",
+		"SSR https://evilwizzair.com/25.3.0":      "",
+		"SSR http://be.wizzair.com/25.3.0":        "",
+		"SSR https://wizzair.com.evil.com/25.3.0": "",
+		"SSR https://be.wizzair.com":              "",
+	} {
+		got, err := parseBuildNumber([]byte(in))
+		if got != want || (err == nil) != (want != "") {
+			t.Errorf("%q: got %q (%+v), wanted %q", in, got, err, want)
+		}
+	}
+	if !validAPIURL(defaultAPIURL) {
+		t.Errorf("the default %q is not valid", defaultAPIURL)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package wizzair
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+)
+
+const mapPath = "/Api/asset/map?languageCode=en-gb"
+
+// Station is an airport of Wizz Air, with its routes.
+type Station struct {
+	IATA        string       `json:"iata"`
+	Name        string       `json:"shortName"`
+	CountryName string       `json:"countryName"`
+	CountryCode string       `json:"countryCode"`
+	Currency    string       `json:"currencyCode"`
+	Aliases     []string     `json:"aliases"`
+	Connections []Connection `json:"connections"`
+	Lat         float64      `json:"latitude"`
+	Lon         float64      `json:"longitude"`
+	Fake        bool         `json:"isFakeStation"`
+}
+
+// Connection is a route from a Station.
+type Connection struct {
+	IATA           string `json:"iata"`
+	OperationStart string `json:"operationStartDate"`
+	RescueEnd      string `json:"rescueEndDate"`
+	Domestic       bool   `json:"isDomestic"`
+	New            bool   `json:"isNew"`
+}
+
+// stations remembers the route map of each API version (of each client), as Destinations needs it for every origin.
+var stations = airline.Memo[airline.ClientKey[string], []Station]{TTL: time.Hour}
+
+// Stations returns all the stations of Wizz Air, with their routes.
+//
+// The result is shared (remembered for an hour), it must not be modified.
+func (co Wizzair) Stations(ctx context.Context) ([]Station, error) {
+	return stations.Do(ctx, airline.MemoKey(co.client, co.apiURL), func(ctx context.Context) ([]Station, error) {
+		sr, _, err := co.client.Get(ctx, co.apiURL+mapPath)
+		if err != nil {
+			return nil, err
+		}
+		var m struct {
+			Cities []Station `json:"cities"`
+		}
+		err = json.NewDecoder(sr).Decode(&m)
+		return m.Cities, err
+	})
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package wizzair
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/tgulacsi/fly/airline"
+	"github.com/tgulacsi/fly/iata"
+)
+
+const (
+	timetablePath = "/Api/search/timetable"
+	searchPath    = "/Api/search/search"
+)
+
+type timetableReq struct {
+	Flights     []timetableFlight `json:"flightList"`
+	PriceType   string            `json:"priceType"`
+	AdultCount  int               `json:"adultCount"`
+	ChildCount  int               `json:"childCount"`
+	InfantCount int               `json:"infantCount"`
+}
+type timetableFlight struct {
+	Origin      string `json:"departureStation"`
+	Destination string `json:"arrivalStation"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+}
+
+// TimetableDay is the cheapest price of a day of a route.
+type TimetableDay struct {
+	Origin      string   `json:"departureStation"`
+	Destination string   `json:"arrivalStation"`
+	Date        string   `json:"departureDate"`
+	PriceType   string   `json:"priceType"`
+	Departures  []string `json:"departureDates"`
+	Price       Price    `json:"price"`
+}
+
+type searchReq struct {
+	Flights        []searchFlight `json:"flightList"`
+	AdultCount     int            `json:"adultCount"`
+	ChildCount     int            `json:"childCount"`
+	InfantCount    int            `json:"infantCount"`
+	WDC            bool           `json:"wdc"`
+	IsFlightChange bool           `json:"isFlightChange"`
+}
+type searchFlight struct {
+	Origin      string `json:"departureStation"`
+	Destination string `json:"arrivalStation"`
+	Date        string `json:"departureDate"`
+}
+
+// Flight is a flight of the search, with its fares of each bundle.
+type Flight struct {
+	Origin       string       `json:"departureStation"`
+	Destination  string       `json:"arrivalStation"`
+	Departure    string       `json:"departureDateTime"`
+	Arrival      string       `json:"arrivalDateTime"`
+	CarrierCode  string       `json:"carrierCode"`
+	FlightNumber string       `json:"flightNumber"`
+	Fares        []FlightFare `json:"fares"`
+}
+
+// FlightFare is the price of a bundle of the flight.
+type FlightFare struct {
+	Bundle          string `json:"bundle"`
+	BasePrice       Price  `json:"basePrice"`
+	DiscountedPrice Price  `json:"discountedPrice"`
+	WDC             bool   `json:"wdc"`
+}
+
+// DailyPrices returns the cheapest price of each day of the route in the range
+// (for the members of the Wizz Discount Club in Member mode).
+func (co Wizzair) DailyPrices(ctx context.Context, origin, destination string, dr airline.DateRange) ([]TimetableDay, error) {
+	var resp struct {
+		Outbound []TimetableDay `json:"outboundFlights"`
+	}
+	priceType := "regular"
+	if co.Member {
+		priceType = "wdc"
+	}
+	err := co.post(ctx, co.apiURL+timetablePath, timetableReq{
+		Flights: []timetableFlight{{
+			Origin: origin, Destination: destination,
+			From: dr.From.Format("2006-01-02"), To: dr.To.Format("2006-01-02"),
+		}},
+		// one adult, as Search rejects the passengers
+		PriceType: priceType, AdultCount: 1,
+	}, &resp)
+	return resp.Outbound, err
+}
+
+// Flights returns the flights of the route on the day.
+func (co Wizzair) Flights(ctx context.Context, origin, destination string, day string) ([]Flight, error) {
+	var resp struct {
+		Outbound []Flight `json:"outboundFlights"`
+	}
+	err := co.post(ctx, co.apiURL+searchPath, searchReq{
+		Flights: []searchFlight{{Origin: origin, Destination: destination, Date: day}},
+		// one adult, as Search rejects the passengers
+		AdultCount: 1, WDC: true,
+	}, &resp)
+	return resp.Outbound, err
+}
+
+// timetableFares returns the flights of the days of the month of departDate which have any.
+//
+// The price of a flight is of the Bundle; the flights without a price (sold out, not for sale,
+// or without the Bundle) are left out.
+// The departure and arrival times are in the time zone of the airports.
+func (co Wizzair) timetableFares(ctx context.Context, origin, destination string, departDate time.Time, currency string) ([]airline.Fare, error) {
+	originTZ, destTZ := iata.Get(origin).Location, iata.Get(destination).Location
+	if originTZ == nil || destTZ == nil {
+		return nil, fmt.Errorf("%s-%s: unknown time zone", origin, destination)
+	}
+	days, err := co.DailyPrices(ctx, origin, destination, co.Span(departDate))
+	if err != nil {
+		return nil, err
+	}
+	bundle := strings.ToLower(cmp.Or(co.Bundle, "basic"))
+	grp, grpCtx := errgroup.WithContext(ctx)
+	grp.SetLimit(4)
+	var mu sync.Mutex
+	var ff []airline.Fare
+	for _, d := range days {
+		if d.Price.Value == 0 || len(d.Departures) == 0 {
+			continue
+		}
+		day, _, _ := strings.Cut(d.Date, "T")
+		grp.Go(func() error {
+			flights, err := co.Flights(grpCtx, origin, destination, day)
+			if err != nil {
+				return err
+			}
+			local := make([]airline.Fare, 0, len(flights))
+			for _, f := range flights {
+				const timePat = "2006-01-02T15:04:05"
+				departure, err := time.ParseInLocation(timePat, f.Departure, originTZ)
+				if err != nil {
+					return err
+				}
+				arrival, err := time.ParseInLocation(timePat, f.Arrival, destTZ)
+				if err != nil {
+					return err
+				}
+				regular, regularWas := f.bundlePrice(bundle, false)
+				member, memberWas := f.bundlePrice(bundle, true)
+				price, memberPrice, regularPrice, was := co.prices(regular, regularWas, member, memberWas)
+				if price.IsZero() {
+					continue
+				}
+				local = append(local, airline.Fare{
+					Airline:       airlineName,
+					Source:        sourceName,
+					Origin:        origin,
+					Destination:   destination,
+					Day:           day,
+					Price:         price,
+					MemberPrice:   memberPrice,
+					RegularPrice:  regularPrice,
+					OriginalPrice: was,
+					Departure:     departure,
+					Arrival:       arrival,
+					Bundle:        strings.ToUpper(bundle[:1]) + bundle[1:],
+				}.Direct(f.CarrierCode+f.FlightNumber))
+			}
+			mu.Lock()
+			ff = append(ff, local...)
+			mu.Unlock()
+			return nil
+		})
+	}
+	err = grp.Wait()
+	slices.SortFunc(ff, func(a, b airline.Fare) int { return a.Departure.Compare(b.Departure) })
+	if err != nil {
+		return ff, err
+	}
+	return airline.ConvertFares(ctx, ff, currency)
+}
+
+// bundlePrice returns the (discounted) price of the bundle, for members (wdc) or not,
+// and the price before the discount.
+func (f Flight) bundlePrice(bundle string, wdc bool) (price, was Price) {
+	for _, fare := range f.Fares {
+		if fare.WDC != wdc || !strings.EqualFold(fare.Bundle, bundle) {
+			continue
+		}
+		if fare.DiscountedPrice.Value != 0 {
+			return fare.DiscountedPrice, fare.BasePrice
+		}
+		return fare.BasePrice, Price{}
+	}
+	return Price{}, Price{}
+}
+
+// post the request as JSON to URL, and decode the response into resp.
+func (co Wizzair) post(ctx context.Context, URL string, req, resp any) error {
+	logger := airline.CtxLogger(ctx)
+	b, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		logger.Debug("POST", "url", URL, "request", string(b))
+	}
+	sr, _, err := co.client.Post(ctx, URL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("%s [%s]: %w", URL, string(b), err)
+	}
+	if err = json.NewDecoder(sr).Decode(resp); err != nil {
+		return fmt.Errorf("%s [%s]: decode: %w", URL, string(b), err)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ package wizzair
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -14,23 +15,25 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/cockroachdb/apd/v3"
-	"github.com/tgulacsi/mnbarf/mnb"
-
 	"github.com/tgulacsi/fly/airline"
 	"github.com/tgulacsi/fly/iata"
 	// "golang.org/x/net/publicsuffix"
 )
 
-const airportsURL = `https://www.ryanair.com/api/views/locate/searchWidget/routes/en/airport/{{origin}}`
-
-var apdCtx = apd.BaseContext.WithPrecision(5)
+const (
+	homeURL        = "https://wizzair.com/en-gb"
+	buildNumberURL = "https://wizzair.com/buildnumber"
+	// defaultAPIURL is used when the version of the API cannot be discovered.
+	defaultAPIURL = "https://be.wizzair.com/24.6.0"
+)
 
+// New returns a Wizzair client, with the cookies of the site and the current version of the API.
 func New(ctx context.Context, client *http.Client) (Wizzair, error) {
-	logger := airline.CtxLogger(ctx)
 	if client == nil {
 		client = http.DefaultClient
 	}
@@ -40,9 +43,10 @@ func New(ctx context.Context, client *http.Client) (Wizzair, error) {
 	if err != nil {
 		return Wizzair{}, err
 	}
-	client.Jar = jar
+	cl := *client
+	cl.Jar = jar
 	var cookies []*http.Cookie
-	wz := Wizzair{client: airline.NewClient(client, false).
+	wz := Wizzair{apiURL: defaultAPIURL, client: airline.NewClient(&cl, false).
 		SetPrepare(func(r *http.Request) {
 			r.Header.Set("Content-Type", "application/json")
 			r.Header.Set(
@@ -53,136 +57,147 @@ func New(ctx context.Context, client *http.Client) (Wizzair, error) {
 			}
 		}),
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	// keep the values (the logger) of ctx, but not its cancellation
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
 	defer cancel()
-	_, resp, err := wz.client.Get(ctx, "https://wizzair.com/en-gb")
+	_, resp, err := wz.client.Get(ctx, homeURL)
 	if err == nil && len(resp.Cookies()) == 0 {
 		err = fmt.Errorf("got no cookies from wizzair.com")
 	}
 	if err != nil {
 		return wz, err
 	}
-	wsC := mnb.NewMNBArfolyamService("", nil, logger.With("lib", "mnb"))
-	dayRates, err := wsC.GetCurrentExchangeRates(ctx)
-	wz.rates = make(map[string]apd.Decimal, len(dayRates.Rates))
-	for _, r := range dayRates.Rates {
-		d := r.Rate.Decimal
-		if r.Unit != 0 && r.Unit != 1 {
-			apdCtx.Quo(d, d, apd.NewWithBigInt(apd.NewBigInt(int64(r.Unit)), 0))
+	if apiURL, err := wz.discoverAPI(ctx); err != nil {
+		airline.CtxLogger(ctx).Warn("discover API version", "source", sourceName, "default", wz.apiURL, "error", err)
+	} else {
+		wz.apiURL = apiURL
+	}
+	return wz, nil
+}
+
+// discoverAPI returns the URL of the current API from the build number of the site
+// ("SSR https://be.wizzair.com/24.6.0").
+func (co Wizzair) discoverAPI(ctx context.Context) (string, error) {
+	sr, _, err := co.client.Get(ctx, buildNumberURL)
+	if err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(sr)
+	if err != nil {
+		return "", err
+	}
+	return parseBuildNumber(b)
+}
+
+// parseBuildNumber returns the API URL from the build number.
+func parseBuildNumber(b []byte) (string, error) {
+	for _, f := range strings.Fields(string(b)) {
+		if validAPIURL(f) {
+			return strings.TrimSuffix(f, "/"), nil
 		}
-		wz.rates[r.Currency] = *d
 	}
-	return wz, err
+	return "", fmt.Errorf("no API URL in %q", b)
+}
+
+// validAPIURL reports whether s is an https URL with a path (the version) on an apiHost.
+func validAPIURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme == "https" && apiHost(u.Host) && u.Path != ""
 }
 
+// apiHost reports whether the API may be on host: a subdomain of wizzair.com.
+func apiHost(host string) bool { return strings.HasSuffix(host, ".wizzair.com") }
+
 type Wizzair struct {
 	client airline.HTTPClient
-	rates  map[string]apd.Decimal
+	// apiURL is the base URL of the current version of the API.
+	apiURL string
+	Options
+}
+
+// Options of the Wizzair source.
+type Options struct {
+	// Bundle is the fare bundle (basic, middle or plus) of the prices in Timetable mode,
+	// CheapFlights returns only the basic ones.
+	Bundle string
+	// Timetable mode returns the fares of each day, with exact times,
+	// instead of the cheapest fare of each destination.
+	Timetable bool
+	// Member returns the prices for the members of the Wizz Discount Club as Price (and MemberPrice),
+	// the regular ones are in RegularPrice. Without it, Price is the regular price, and MemberPrice the member one.
+	Member bool
 }
 
+// bundles are the fare bundles of Wizz Air.
+var bundles = []string{"basic", "middle", "plus"}
+
+// Check returns an error for an unknown Bundle, or for a Bundle other than basic without Timetable mode,
+// as CheapFlights returns only the basic prices.
+func (o Options) Check() error {
+	bundle := strings.ToLower(cmp.Or(o.Bundle, "basic"))
+	if !slices.Contains(bundles, bundle) {
+		return fmt.Errorf("unknown Wizz Air bundle %q (%s)", o.Bundle, strings.Join(bundles, ", "))
+	}
+	if bundle != "basic" && !o.Timetable {
+		return fmt.Errorf("the Wizz Air bundle %q needs Timetable mode", o.Bundle)
+	}
+	return nil
+}
+
+// DefaultOptions are the Options of the registered source.
+var DefaultOptions = Options{Bundle: "basic"}
+
 var _ airline.Airline = Wizzair{}
+var _ airline.Spanner = Wizzair{}
+var _ airline.AirlineSearch = Wizzair{}
 
 const (
 	airlineName = "Wizz Air"
 	sourceName  = "wizzair"
 )
 
+func init() {
+	airline.Register(sourceName, func(ctx context.Context) (airline.Airline, error) {
+		wz, err := New(ctx, nil)
+		wz.Options = DefaultOptions
+		return wz, err
+	})
+}
+
 func (co Wizzair) Destinations(ctx context.Context, origin string) ([]string, error) {
 	aa, err := co.FullDestinations(ctx, origin)
 	dests := make([]string, len(aa))
 	for i, a := range aa {
-		dests[i] = a.Code
+		dests[i] = a.IATA
 	}
 	return dests, err
 }
 
-func (co Wizzair) FullDestinations(ctx context.Context, origin string) ([]ArrivalAirport, error) {
-	sr, _, err := co.client.Get(ctx, strings.Replace(airportsURL, "{{origin}}", origin, 1))
+// FullDestinations returns the stations reachable directly from origin,
+// or an error wrapping airline.ErrUnknownOrigin if origin is not a station of Wizz Air.
+func (co Wizzair) FullDestinations(ctx context.Context, origin string) ([]Station, error) {
+	all, err := co.Stations(ctx)
 	if err != nil {
 		return nil, err
 	}
-	var arrivals []struct {
-		Airport ArrivalAirport `json:"arrivalAirport"`
+	byCode := make(map[string]Station, len(all))
+	for _, st := range all {
+		byCode[st.IATA] = st
 	}
-	err = json.NewDecoder(sr).Decode(&arrivals)
-	arrs := make([]ArrivalAirport, len(arrivals))
-	for i, a := range arrivals {
-		arrs[i] = a.Airport
+	from, ok := byCode[origin]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", origin, airline.ErrUnknownOrigin)
 	}
-	return arrs, err
-}
-
-/*
-[
-
-		{
-	    "arrivalAirport": {
-	      "aliases": [],
-	      "base": true,
-	      "city": {
-	        "code": "MALAGA",
-	        "name": "Malaga"
-	      },
-	      "code": "AGP",
-	      "coordinates": {
-	        "latitude": 36.6749,
-	        "longitude": -4.49911
-	      },
-	      "country": {
-	        "code": "es",
-	        "currency": "EUR",
-	        "defaultAirportCode": "BCN",
-	        "iso3code": "ESP",
-	        "name": "Spain",
-	        "schengen": true
-	      },
-	      "name": "Malaga",
-	      "region": {
-	        "code": "COSTA_DE_SOL",
-	        "name": "Costa del Sol"
-	      },
-	      "seoName": "malaga",
-	      "timeZone": "Europe/Madrid"
-	    },
-	    "operator": "FR",
-	    "recent": false,
-	    "seasonal": false,
-	    "tags": []
-	  },
-
-	  ]
-*/
-type ArrivalAirport struct {
-	Country     Country    `json:"country"`
-	City        NameCode   `json:"city"`
-	Region      NameCode   `json:"region"`
-	Code        string     `json:"code"`
-	Name        string     `json:"name"`
-	SEO         string     `json:"seoName"`
-	Operator    string     `json:"operator"`
-	TimeZone    string     `json:"timeZone"`
-	Aliases     []string   `json:"aliases"`
-	Tags        []string   `json:"tags"`
-	Coordinates Coordinate `json:"coordinates"`
-	Base        bool       `json:"base"`
-	Recent      bool       `json:"recent"`
-	Seasonal    bool       `json:"seasonal"`
-}
-type Country struct {
-	NameCode
-	Currency       string `json:"currency"`
-	DefaultAirport string `json:"defaultAirportCode"`
-}
-type NameCode struct {
-	Name string `json:"name"`
-	Code string `json:"code"`
-}
-type Coordinate struct {
-	Lat float64 `json:"latitude"`
-	Lon float64 `json:"longitude"`
+	dests := make([]Station, 0, len(from.Connections))
+	for _, c := range from.Connections {
+		if st, ok := byCode[c.IATA]; ok && !st.Fake {
+			dests = append(dests, st)
+		}
+	}
+	return dests, nil
 }
 
-const faresURL = `https://be.wizzair.com/24.6.0/Api/search/CheapFlights`
+const faresPath = "/Api/search/CheapFlights"
 
 type faresReq struct {
 	Origin         string `json:"departureStation"`
@@ -191,6 +206,9 @@ type faresReq struct {
 }
 
 func (co Wizzair) Fares(ctx context.Context, origin, destination string, departDate time.Time, currency string) ([]airline.Fare, error) {
+	if co.Timetable {
+		return co.timetableFares(ctx, origin, destination, departDate, currency)
+	}
 	allFares, err := co.AllFares(ctx, origin, departDate, currency)
 	fares := make([]airline.Fare, 0, len(allFares))
 	for _, f := range allFares {
@@ -201,60 +219,149 @@ func (co Wizzair) Fares(ctx context.Context, origin, destination string, departD
 	return fares, err
 }
 
+// cheapFlights remembers the CheapFlights lists (of each API version and client), as Fares needs the whole list for each destination.
+var cheapFlights = airline.Memo[airline.ClientKey[cheapKey], []Fare]{TTL: 10 * time.Minute}
+
+// cheapKey is the request of an API version.
+type cheapKey struct {
+	apiURL string
+	faresReq
+}
+
+// cheapFlights returns the CheapFlights list of the request.
+//
+// The result is shared (remembered for a while), it must not be modified.
+func (co Wizzair) cheapFlights(ctx context.Context, req faresReq) ([]Fare, error) {
+	return cheapFlights.Do(ctx, airline.MemoKey(co.client, cheapKey{apiURL: co.apiURL, faresReq: req}), func(ctx context.Context) ([]Fare, error) {
+		logger := airline.CtxLogger(ctx)
+		b, err := json.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("marshal fares request: %w", err)
+		}
+		faresURL := co.apiURL + faresPath
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			logger.Debug("POST", "url", faresURL, "request", string(b))
+		}
+		sr, _, err := co.client.Post(ctx, faresURL, bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("%s [%s]: %w", faresURL, string(b), err)
+		}
+		var fares struct {
+			Fares []Fare `json:"items"`
+		}
+		var buf strings.Builder
+		io.Copy(&buf, io.NewSectionReader(sr, 0, sr.Size()))
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			logger.Debug(buf.String())
+		}
+		err = json.NewDecoder(sr).Decode(&fares)
+		return fares.Fares, err
+	})
+}
+
 func (co Wizzair) AllFares(ctx context.Context, origin string, departDate time.Time, currency string) ([]airline.Fare, error) {
-	logger := airline.CtxLogger(ctx)
-	originTZ, _ := time.LoadLocation(iata.Get(origin).TimeZone)
-	months := 6
-	now := time.Now()
-	for now.AddDate(0, months, 0).Before(departDate) {
-		months++
-	}
-	b, err := json.Marshal(faresReq{Origin: origin, Months: months})
-	if err != nil {
-		return nil, fmt.Errorf("marshal fares request: %w", err)
-	}
-	if logger.Enabled(ctx, slog.LevelDebug) {
-		logger.Debug("POST", "url", faresURL, "request", string(b))
-	}
-	sr, _, err := co.client.Post(ctx, faresURL, bytes.NewReader(b))
-	if err != nil {
-		return nil, fmt.Errorf("%s [%s]: %w", faresURL, string(b), err)
-	}
-	var fares struct {
-		Fares []Fare `json:"items"`
-	}
-	var buf strings.Builder
-	io.Copy(&buf, io.NewSectionReader(sr, 0, sr.Size()))
-	if logger.Enabled(ctx, slog.LevelDebug) {
-		logger.Debug(buf.String())
+	return co.allFares(ctx, origin, departDate, currency, cheapMonths(time.Now(), co.Span(departDate).To))
+}
+
+// allFares is AllFares, asking CheapFlights for the given number of months.
+func (co Wizzair) allFares(ctx context.Context, origin string, departDate time.Time, currency string, months int) ([]airline.Fare, error) {
+	if co.Timetable {
+		// ask each destination
+		return airline.WithAllFares(struct{ airline.Airline }{co}).AllFares(ctx, origin, departDate, currency)
 	}
-	err = json.NewDecoder(sr).Decode(&fares)
-	ff := make([]airline.Fare, 0, len(fares.Fares))
-	for _, f := range fares.Fares {
+	originTZ, _ := time.LoadLocation(iata.Get(origin).TimeZone)
+	fares, err := co.cheapFlights(ctx, faresReq{Origin: origin, Months: months})
+	ff := make([]airline.Fare, 0, len(fares))
+	for _, f := range fares {
 		const timePat = "2006-01-02T15:04:05"
 		departure, err := time.ParseInLocation(timePat, f.Departure, originTZ)
 		if err != nil {
 			return ff, err
 		}
-		if !departDate.IsZero() && departDate.Sub(departure).Abs() > 6*24*time.Hour {
+		day := departure.Format("2006-01-02")
+		if !departDate.IsZero() && !co.Span(departDate).Contains(day) {
 			continue
 		}
-		price, err := co.Convert(f.RegularPrice, "EUR")
-		if err != nil {
-			return ff, err
-		}
+		// regularPrice is of the basic bundle, wdcPrice is for the members of the Wizz Discount Club
+		price, memberPrice, regularPrice, was := co.prices(f.RegularPrice, f.RegularOriginalPrice, f.WDCPrice, f.WDCOriginalPrice)
 		ff = append(ff, airline.Fare{
-			Airline:     airlineName,
-			Source:      sourceName,
-			Origin:      f.Origin,
-			Destination: f.Destination,
-			Price:       price.Value,
-			Currency:    price.Currency,
-			Departure:   departure,
-			Day:         departure.Format("2006-01-02"),
-		})
+			Airline:       airlineName,
+			Source:        sourceName,
+			Origin:        f.Origin,
+			Destination:   f.Destination,
+			Price:         price,
+			MemberPrice:   memberPrice,
+			RegularPrice:  regularPrice,
+			OriginalPrice: was,
+			Departure:     departure,
+			Day:           day,
+			Bundle:        "Basic",
+		}.Direct(""))
+	}
+	if err != nil {
+		return ff, err
+	}
+	return airline.ConvertFares(ctx, ff, currency)
+}
+
+// prices returns the price (the member price in Member mode), the member price,
+// the regular price if it is not the price, and the price before the discount ("was" price) of price, if it is higher.
+func (o Options) prices(regular, regularWas, member, memberWas Price) (price, memberPrice, regularPrice, was airline.Money) {
+	if member.Value == 0 || member.Currency != regular.Currency {
+		member, memberWas = Price{}, Price{}
+	}
+	p, w := regular, regularWas
+	if o.Member && member.Value != 0 {
+		p, w = member, memberWas
+		regularPrice = regular.Money()
+	}
+	if w.Value <= p.Value || w.Currency != p.Currency {
+		w = Price{}
+	}
+	return p.Money(), member.Money(), regularPrice, w.Money()
+}
+
+// Search is airline.SearchWith the options of CheapFlights,
+// which is asked for as many months as the windows of the request need.
+//
+// The passengers and the cabin are rejected in Timetable mode too:
+// all the requests ask for the economy price of one adult.
+func (co Wizzair) Search(ctx context.Context, req airline.SearchRequest) ([]airline.Fare, error) {
+	last := req.Departure.To
+	if req.Return.To.After(last) {
+		last = req.Return.To
 	}
-	return ff, err
+	return airline.SearchWith(ctx, searcher{Wizzair: co, months: cheapMonths(time.Now(), last)}, req, airline.OptFares)
+}
+
+// searcher is the Wizzair of a Search, which asks CheapFlights for the months of the whole request.
+type searcher struct {
+	Wizzair
+	months int
+}
+
+func (s searcher) AllFares(ctx context.Context, origin string, departDate time.Time, currency string) ([]airline.Fare, error) {
+	return s.allFares(ctx, origin, departDate, currency, s.months)
+}
+
+// cheapMonths returns the number of months (counted from now, at least 6) which CheapFlights
+// has to be asked for to cover last.
+func cheapMonths(now, last time.Time) int {
+	months := 6
+	for now.AddDate(0, months, 0).Before(last) {
+		months++
+	}
+	return months
+}
+
+// Span returns the ±6 days around departure, as CheapFlights returns only one fare per destination;
+// or the month of departure in Timetable mode.
+func (co Wizzair) Span(departure time.Time) airline.DateRange {
+	if co.Timetable {
+		first := time.Date(departure.Year(), departure.Month(), 1, 0, 0, 0, 0, departure.Location())
+		return airline.DateRange{From: first, To: first.AddDate(0, 1, -1)}
+	}
+	return airline.Flex(departure, 6)
 }
 
 type Fare struct {
@@ -276,21 +383,5 @@ type Price struct {
 	Value    float64 `json:"amount"`
 }
 
-func (wz Wizzair) Convert(p Price, to string) (Price, error) {
-	v, err := apd.New(0, 0).SetFloat64(p.Value)
-	if err != nil {
-		return p, err
-	}
-	c := apd.MakeErrDecimal(apdCtx)
-	if p.Currency != "HUF" {
-		r := wz.rates[p.Currency]
-		c.Mul(v, v, &r)
-	}
-	r := wz.rates[to]
-	c.Quo(v, v, &r)
-	f, err := v.Float64()
-	return Price{
-		Currency: to,
-		Value:    f,
-	}, err
-}
+// Money returns the price (rounded to 4 fractional digits, so exact for the 2 digit prices).
+func (p Price) Money() airline.Money { return airline.NewMoney(p.Value, p.Currency) }
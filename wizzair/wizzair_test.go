@@ -0,0 +1,154 @@
+// Copyright 2024 Tamás Gulácsi. All rights reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package wizzair_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tgulacsi/fly/airline"
+	"github.com/tgulacsi/fly/wizzair"
+)
+
+func TestReplay(t *testing.T) {
+	t.Setenv("FLY_REPLAY", "testdata/replay")
+	ctx := context.Background()
+	wz, err := wizzair.New(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2024, 10, 15, 0, 0, 0, 0, time.Local)
+	all, err := wz.AllFares(ctx, "BUD", day, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the December fare is out of the span
+	if len(all) != 2 {
+		t.Fatalf("got %d fares, wanted 2: %+v", len(all), all)
+	}
+
+	fares, err := wz.Fares(ctx, "BUD", "LTN", day, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fares) != 1 {
+		t.Fatalf("got %d fares, wanted 1: %+v", len(fares), fares)
+	}
+	if f := fares[0]; f.Day != "2024-10-16" || f.Price != airline.NewMoney(22.99, "EUR") || f.Airline != "Wizz Air" {
+		t.Errorf("got %+v", f)
+	} else if f.MemberPrice != airline.NewMoney(12.99, "EUR") || f.Bundle != "Basic" {
+		t.Errorf("got member price %v, bundle %q, wanted 12.99 Basic", f.MemberPrice, f.Bundle)
+	} else if f.OriginalPrice != airline.NewMoney(29.99, "EUR") {
+		t.Errorf("got original price %v, wanted 29.99", f.OriginalPrice)
+	}
+
+	// the members see their own prices
+	wz.Member = true
+	if fares, err = wz.Fares(ctx, "BUD", "LTN", day, "EUR"); err != nil {
+		t.Fatal(err)
+	}
+	if f := fares[0]; f.Price != airline.NewMoney(12.99, "EUR") || f.MemberPrice != f.Price ||
+		f.RegularPrice != airline.NewMoney(22.99, "EUR") || f.OriginalPrice != airline.NewMoney(19.99, "EUR") {
+		t.Errorf("member: got price %v, member price %v, regular price %v, original price %v, wanted 12.99, 12.99, 22.99, 19.99",
+			f.Price, f.MemberPrice, f.RegularPrice, f.OriginalPrice)
+	}
+	for _, o := range []wizzair.Options{{Bundle: "gold", Timetable: true}, {Bundle: "plus"}} {
+		if err := o.Check(); err == nil {
+			t.Errorf("%+v: wanted error", o)
+		}
+	}
+	if err := (wizzair.Options{Bundle: "Plus", Timetable: true}).Check(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTimetable(t *testing.T) {
+	t.Setenv("FLY_REPLAY", "testdata/replay")
+	ctx := context.Background()
+	wz, err := wizzair.New(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wz.Timetable = true
+
+	day := time.Date(2024, 10, 15, 0, 0, 0, 0, time.Local)
+	if span := wz.Span(day); span.From.Day() != 1 || span.To.Day() != 31 {
+		t.Errorf("got span %v, wanted the whole October", span)
+	}
+	fares, err := wz.Fares(ctx, "BUD", "LTN", day, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the sold out day is skipped, the second flight of the 20th has no fares
+	if len(fares) != 2 {
+		t.Fatalf("got %d fares, wanted 2: %+v", len(fares), fares)
+	}
+	f := fares[0]
+	if f.Day != "2024-10-16" || f.Price != airline.NewMoney(22.99, "EUR") || f.Bundle != "Basic" {
+		t.Errorf("got %+v", f)
+	}
+	if got := f.Departure.Format("15:04 MST"); got != "06:00 CEST" {
+		t.Errorf("got departure %s, wanted 06:00 CEST", got)
+	}
+	if got := f.Arrival.Format("15:04 MST"); got != "07:50 BST" {
+		t.Errorf("got arrival %s, wanted 07:50 BST", got)
+	}
+	if f.Duration != 2*time.Hour+50*time.Minute || len(f.FlightNumbers) != 1 || f.FlightNumbers[0] != "W62201" {
+		t.Errorf("got duration %s, flight %q", f.Duration, f.FlightNumbers)
+	}
+	if f.MemberPrice != airline.NewMoney(12.99, "EUR") || !f.OriginalPrice.IsZero() {
+		t.Errorf("got member price %v, original price %v, wanted 12.99 and none", f.MemberPrice, f.OriginalPrice)
+	}
+	// the discounted basic price
+	if f := fares[1]; f.Day != "2024-10-20" || f.Price != airline.NewMoney(21.49, "EUR") || f.OriginalPrice != airline.NewMoney(24.99, "EUR") {
+		t.Errorf("got %s %v (original price %v), wanted 2024-10-20 21.49 (24.99)", f.Day, f.Price, f.OriginalPrice)
+	}
+	// without plus fares, not even the only flight of the 16th gets the (basic) price of the day
+	wz.Bundle = "plus"
+	if fares, err = wz.Fares(ctx, "BUD", "LTN", day, "EUR"); err != nil {
+		t.Fatal(err)
+	} else if len(fares) != 0 {
+		t.Errorf("got %+v, wanted no plus fares", fares)
+	}
+	wz.Bundle = ""
+
+	// the requests are for one adult
+	if _, err := wz.Search(ctx, airline.SearchRequest{
+		Origin: "BUD", Destination: "LTN", Departure: airline.Day(day), Currency: "EUR",
+		Passengers: airline.Passengers{Adults: 2},
+	}); !errors.Is(err, airline.ErrUnsupported) {
+		t.Errorf("got %+v, wanted ErrUnsupported for two adults", err)
+	}
+}
+
+func TestDestinations(t *testing.T) {
+	t.Setenv("FLY_REPLAY", "testdata/replay")
+	ctx := context.Background()
+	wz, err := wizzair.New(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fake (city) station and the unknown ones are skipped
+	dests, err := wz.FullDestinations(ctx, "BUD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dests) != 2 || dests[0].IATA != "LTN" || dests[1].IATA != "CRL" {
+		t.Fatalf("got %+v, wanted LTN and CRL", dests)
+	}
+	if d := dests[1]; d.Name != "Brussels Charleroi" || d.CountryCode != "BE" || d.Currency != "EUR" || len(d.Connections) != 1 {
+		t.Errorf("got %+v", d)
+	}
+	codes, err := wz.Destinations(ctx, "LTN")
+	if err != nil || len(codes) != 1 || codes[0] != "BUD" {
+		t.Errorf("got %q (%+v), wanted BUD", codes, err)
+	}
+	if codes, err := wz.Destinations(ctx, "DUB"); !errors.Is(err, airline.ErrUnknownOrigin) || len(codes) != 0 {
+		t.Errorf("got %q (%+v) for a station without Wizz Air, wanted ErrUnknownOrigin", codes, err)
+	}
+}